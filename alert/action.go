@@ -0,0 +1,87 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// actionTimeout bounds how long a single action may run
+const actionTimeout = 30 * time.Second
+
+// Action is something done when a target changes state
+type Action interface {
+	Run(ev Event) error
+}
+
+// Command runs a shell command with the event described in IPMASTER_*
+// environment variables
+type Command string
+
+func (c Command) Run(ev Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", string(c))
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", string(c))
+	}
+	cmd.Env = append(os.Environ(),
+		"IPMASTER_TIME="+ev.Time.Format(time.RFC3339),
+		"IPMASTER_TARGET="+ev.Target,
+		"IPMASTER_HOST="+ev.Host,
+		"IPMASTER_ADDR="+ev.Addr,
+		"IPMASTER_PROBE="+ev.Probe,
+		"IPMASTER_FROM="+ev.From.String(),
+		"IPMASTER_STATE="+ev.To.String(),
+		"IPMASTER_RTT_MS="+strconv.FormatFloat(ev.RTT, 'f', 2, 64),
+		"IPMASTER_AVG_MS="+strconv.FormatFloat(ev.AvgRTT, 'f', 2, 64),
+		"IPMASTER_LOSS="+strconv.FormatFloat(ev.Loss, 'f', 1, 64),
+		"IPMASTER_REASON="+ev.Reason,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("command %q failed: %v: %s", string(c), err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Webhook POSTs the event as JSON to a URL
+type Webhook string
+
+func (w Webhook) Run(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: actionTimeout}
+	resp, err := client.Post(string(w), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook %s failed: %w", string(w), err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s returned status %d", string(w), resp.StatusCode)
+	}
+	return nil
+}
+
+// Dispatch runs every action for ev in the background, logging failures
+func Dispatch(actions []Action, ev Event) {
+	for _, action := range actions {
+		go func(action Action) {
+			if err := action.Run(ev); err != nil {
+				log.Printf("Alert action for %s: %v", ev.Target, err)
+			}
+		}(action)
+	}
+}
@@ -0,0 +1,174 @@
+package alert
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// State is the health of a monitored target
+type State int
+
+const (
+	Unknown  State = iota // No verdict yet
+	Up                    // Replying within the degraded threshold
+	Degraded              // Replying, but the recent average RTT is too high
+	Down                  // Too many consecutive probes failed
+)
+
+var stateNames = [...]string{"unknown", "up", "degraded", "down"}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Rules decide when a target changes state
+type Rules struct {
+	DownAfter int           // Consecutive losses after which a target is down
+	UpAfter   int           // Consecutive replies after which a down target is up again
+	Degraded  time.Duration // Recent average RTT above which a target is degraded, zero disables
+}
+
+// DefaultRules marks a target down after 3 losses and up again after 1 reply
+func DefaultRules() Rules {
+	return Rules{DownAfter: 3, UpAfter: 1}
+}
+
+// Event describes a state transition of one target
+type Event struct {
+	Time   time.Time `json:"time"`
+	Target string    `json:"target"` // Target spec as entered on the Ping page
+	Host   string    `json:"host"`
+	Addr   string    `json:"addr,omitempty"`
+	Probe  string    `json:"probe"` // Probe label such as ICMP or TCP/443
+	From   State     `json:"from"`
+	To     State     `json:"to"`
+	RTT    float64   `json:"rtt_ms"` // RTT of the probe that caused the transition
+	AvgRTT float64   `json:"avg_ms"` // Recent average RTT
+	Loss   float64   `json:"loss"`   // Recent loss percentage
+	Reason string    `json:"reason"`
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s (%s): %s → %s, %s", e.Time.Format("15:04:05"), e.Target, e.Probe, e.From, e.To, e.Reason)
+}
+
+// Tracker applies Rules to the results of many targets and reports the
+// transitions. It is safe for concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	rules   Rules
+	targets map[string]*targetState
+}
+
+// targetState is what the tracker remembers about one target
+type targetState struct {
+	state     State
+	losses    int // Consecutive failed probes
+	successes int // Consecutive replies
+}
+
+// NewTracker creates a Tracker applying rules
+func NewTracker(rules Rules) *Tracker {
+	return &Tracker{rules: rules, targets: make(map[string]*targetState)}
+}
+
+// SetRules replaces the rules; current states are kept
+func (t *Tracker) SetRules(rules Rules) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rules = rules
+}
+
+// SetTargets forgets every target not in targets, so one that is removed
+// and monitored again later starts over from Unknown
+func (t *Tracker) SetTargets(targets []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keep := make(map[string]*targetState, len(targets))
+	for _, target := range targets {
+		if ts, ok := t.targets[target]; ok {
+			keep[target] = ts
+		}
+	}
+	t.targets = keep
+}
+
+// State returns the current state of target
+func (t *Tracker) State(target string) State {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ts, ok := t.targets[target]; ok {
+		return ts.state
+	}
+	return Unknown
+}
+
+// Observe feeds a probe result together with the target's recent statistics
+// and returns the event if the target changed state. The first verdict of a
+// target is only reported when it is not up.
+func (t *Tracker) Observe(res ping.Result, recent ping.Statistics) (Event, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ts, ok := t.targets[res.Target]
+	if !ok {
+		ts = &targetState{}
+		t.targets[res.Target] = ts
+	}
+
+	next, reason := ts.state, ""
+	if res.Err != nil {
+		ts.losses++
+		ts.successes = 0
+		if ts.losses >= max(t.rules.DownAfter, 1) {
+			next = Down
+			reason = fmt.Sprintf("%d consecutive losses, last: %v", ts.losses, res.Err)
+		}
+	} else {
+		ts.losses = 0
+		ts.successes++
+		switch {
+		case ts.state == Down && ts.successes < max(t.rules.UpAfter, 1):
+			// Still down until enough replies came back
+		case t.rules.Degraded > 0 && recent.AvgRTT > t.rules.Degraded:
+			next = Degraded
+			reason = fmt.Sprintf("average RTT %.2f ms above %s", ms(recent.AvgRTT), t.rules.Degraded)
+		default:
+			next = Up
+			reason = fmt.Sprintf("replying, average RTT %.2f ms", ms(recent.AvgRTT))
+		}
+	}
+
+	prev := ts.state
+	ts.state = next
+	if next == prev || (prev == Unknown && next == Up) {
+		return Event{}, false
+	}
+	return Event{
+		Time:   res.Time,
+		Target: res.Target,
+		Host:   res.Spec.Host,
+		Addr:   res.Addr,
+		Probe:  res.Spec.Label(),
+		From:   prev,
+		To:     next,
+		RTT:    ms(res.RTT),
+		AvgRTT: ms(recent.AvgRTT),
+		Loss:   recent.Loss,
+		Reason: reason,
+	}, true
+}
+
+func ms(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
@@ -0,0 +1,135 @@
+package alert
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// step is one probe result fed to a Tracker and the transition it should cause
+type step struct {
+	lost bool
+	avg  time.Duration // Recent average RTT
+	want State         // Reported transition, Unknown for none
+}
+
+// observe feeds steps for target to tr and checks the reported transitions
+func observe(t *testing.T, tr *Tracker, target string, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		res := ping.Result{Target: target, RTT: s.avg}
+		if s.lost {
+			res.Err = errors.New("request timed out")
+		}
+		ev, changed := tr.Observe(res, ping.Statistics{AvgRTT: s.avg})
+		switch {
+		case s.want == Unknown && changed:
+			t.Errorf("step %d: unexpected event %s → %s", i, ev.From, ev.To)
+		case s.want != Unknown && !changed:
+			t.Errorf("step %d: no event, want a change to %s", i, s.want)
+		case changed && ev.To != s.want:
+			t.Errorf("step %d: change to %s, want %s", i, ev.To, s.want)
+		}
+	}
+}
+
+func TestTrackerTransitions(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name  string
+		rules Rules
+		steps []step
+		final State
+	}{
+		{
+			name:  "first reply is silent",
+			rules: DefaultRules(),
+			steps: []step{{avg: ms}, {avg: ms}},
+			final: Up,
+		},
+		{
+			name:  "down after threshold",
+			rules: DefaultRules(),
+			steps: []step{{avg: ms}, {lost: true}, {lost: true}, {lost: true, want: Down}},
+			final: Down,
+		},
+		{
+			name:  "losses below threshold reset by a reply",
+			rules: DefaultRules(),
+			steps: []step{{avg: ms}, {lost: true}, {lost: true}, {avg: ms}, {lost: true}, {lost: true}},
+			final: Up,
+		},
+		{
+			name:  "first verdict down is reported",
+			rules: Rules{DownAfter: 1, UpAfter: 1},
+			steps: []step{{lost: true, want: Down}},
+			final: Down,
+		},
+		{
+			name:  "recovery",
+			rules: Rules{DownAfter: 1, UpAfter: 1},
+			steps: []step{{avg: ms}, {lost: true, want: Down}, {avg: ms, want: Up}},
+			final: Up,
+		},
+		{
+			name:  "recovery needs UpAfter replies",
+			rules: Rules{DownAfter: 1, UpAfter: 3},
+			steps: []step{{lost: true, want: Down}, {avg: ms}, {avg: ms}, {lost: true}, {avg: ms}, {avg: ms}, {avg: ms, want: Up}},
+			final: Up,
+		},
+		{
+			name:  "repeats suppressed",
+			rules: Rules{DownAfter: 2, UpAfter: 1},
+			steps: []step{{avg: ms}, {lost: true}, {lost: true, want: Down}, {lost: true}, {lost: true}, {avg: ms, want: Up}, {avg: ms}},
+			final: Up,
+		},
+		{
+			name:  "degraded and back",
+			rules: Rules{DownAfter: 1, UpAfter: 1, Degraded: 100 * ms},
+			steps: []step{{avg: 10 * ms}, {avg: 200 * ms, want: Degraded}, {avg: 150 * ms}, {avg: 50 * ms, want: Up}},
+			final: Up,
+		},
+		{
+			name:  "down to degraded",
+			rules: Rules{DownAfter: 1, UpAfter: 1, Degraded: 100 * ms},
+			steps: []step{{lost: true, want: Down}, {avg: 200 * ms, want: Degraded}},
+			final: Degraded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(tt.rules)
+			observe(t, tr, "8.8.8.8", tt.steps)
+			if got := tr.State("8.8.8.8"); got != tt.final {
+				t.Errorf("State = %s, want %s", got, tt.final)
+			}
+		})
+	}
+}
+
+func TestTrackerTargetsIndependent(t *testing.T) {
+	tr := NewTracker(Rules{DownAfter: 2, UpAfter: 1})
+	observe(t, tr, "8.8.8.8", []step{{lost: true}})
+	observe(t, tr, "1.1.1.1", []step{{lost: true}})
+	observe(t, tr, "8.8.8.8", []step{{lost: true, want: Down}})
+	if got := tr.State("1.1.1.1"); got != Unknown {
+		t.Errorf("1.1.1.1 is %s after one loss, want unknown", got)
+	}
+}
+
+func TestTrackerSetTargets(t *testing.T) {
+	tr := NewTracker(Rules{DownAfter: 1, UpAfter: 1})
+	observe(t, tr, "8.8.8.8", []step{{lost: true, want: Down}})
+	observe(t, tr, "1.1.1.1", []step{{lost: true, want: Down}})
+
+	tr.SetTargets([]string{"1.1.1.1"})
+	if got := tr.State("8.8.8.8"); got != Unknown {
+		t.Errorf("removed target is %s, want unknown", got)
+	}
+	// Added again, the first reply is a first verdict rather than a recovery
+	observe(t, tr, "8.8.8.8", []step{{avg: time.Millisecond}})
+	// The kept target still recovers
+	observe(t, tr, "1.1.1.1", []step{{avg: time.Millisecond, want: Up}})
+}
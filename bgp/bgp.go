@@ -27,14 +27,25 @@ type BGPResponse struct {
 	} `json:"data"`
 }
 
+// Route is a prefix announced by an AS, as reported by bgpview.io
+type Route struct {
+	Prefix      string `json:"prefix"`
+	ASN         int    `json:"asn"`
+	Description string `json:"description"`
+	Country     string `json:"country"`
+}
+
 // BGP manages BGP route display
 type BGP struct {
 	Prefix     string
+	Source     string  // Where the last lookup found its data: birdc, route print or bgpview.io
+	Routes     []Route // Routes found by the last lookup when Source is bgpview.io
 	resultView *tview.TextView
 	app        *tview.Application
 }
 
-// NewBGP creates a new BGP instance
+// NewBGP creates a new BGP instance. app and resultView may be nil when only
+// Lookup is used.
 func NewBGP(prefix string, app *tview.Application, resultView *tview.TextView) *BGP {
 	return &BGP{
 		Prefix:     prefix,
@@ -45,9 +56,21 @@ func NewBGP(prefix string, app *tview.Application, resultView *tview.TextView) *
 
 // ShowBGPRoutes displays BGP routing information
 func (bgp *BGP) ShowBGPRoutes() error {
-	bgp.resultView.Clear()
-	bgp.resultView.SetText(fmt.Sprintf("Fetching BGP routes for %s...\n", bgp.Prefix))
+	bgp.updateText(fmt.Sprintf("Fetching BGP routes for %s...\n", bgp.Prefix))
+
+	text, err := bgp.Lookup()
+	if err != nil {
+		bgp.updateText(err.Error())
+		return err
+	}
+	bgp.updateText(text)
+	return nil
+}
 
+// Lookup fetches BGP routing information for the prefix, from local tools
+// when available and bgpview.io otherwise, and returns it as text
+func (bgp *BGP) Lookup() (string, error) {
+	bgp.Source, bgp.Routes = "", nil
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("BGP Routes for %s:\n", bgp.Prefix))
 	output.WriteString("--------------------------------------------------\n")
@@ -56,22 +79,22 @@ func (bgp *BGP) ShowBGPRoutes() error {
 	hasBGPData := false
 	if runtime.GOOS == "windows" {
 		hasBGPData = bgp.tryWindowsRoutePrint(&output)
+		bgp.Source = "route print"
 	} else {
 		// Linux-based systems (try birdc)
 		hasBGPData = bgp.tryBirdc(&output)
+		bgp.Source = "birdc"
 	}
 
 	// If no BGP data found locally, use API fallback
 	if !hasBGPData {
 		log.Printf("No BGP data found locally, falling back to bgpview.io API")
+		bgp.Source = "bgpview.io"
 		if err := bgp.fetchFromBGPView(&output); err != nil {
-			bgp.updateText(fmt.Sprintf("Failed to fetch BGP routes: %v\nFallback API also failed: %v", err, err))
-			return err
+			return "", fmt.Errorf("failed to fetch BGP routes: %v", err)
 		}
 	}
-
-	bgp.updateText(output.String())
-	return nil
+	return output.String(), nil
 }
 
 // tryWindowsRoutePrint attempts to fetch BGP routes from route print on Windows
@@ -195,6 +218,7 @@ func (bgp *BGP) fetchFromBGPView(output *strings.Builder) error {
 	output.WriteString("Prefix             ASN    Description                Country\n")
 	for _, prefix := range bgpResp.Data.Prefixes {
 		for _, asn := range prefix.Asns {
+			bgp.Routes = append(bgp.Routes, Route{Prefix: prefix.Prefix, ASN: asn.Asn, Description: asn.Description, Country: asn.CountryCode})
 			output.WriteString(fmt.Sprintf("%-18s %-6d %-25s %-2s\n",
 				prefix.Prefix, asn.Asn, asn.Description, asn.CountryCode))
 		}
@@ -204,6 +228,9 @@ func (bgp *BGP) fetchFromBGPView(output *strings.Builder) error {
 
 // updateText updates the TextView with the current result
 func (bgp *BGP) updateText(text string) {
+	if bgp.app == nil {
+		return
+	}
 	bgp.app.QueueUpdateDraw(func() {
 		bgp.resultView.SetText(text)
 	})
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/a-tharva/ipmaster/resolve"
+)
+
+// Exit codes returned by Run
+const (
+	ExitOK     = 0 // Every check passed
+	ExitFailed = 1 // A target was unreachable, a port closed or a trace incomplete
+	ExitUsage  = 2 // Invalid command line
+	ExitError  = 3 // The tool itself failed, e.g. no permission for raw sockets
+)
+
+// command is a subcommand taking its arguments and output streams
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+// commands is filled in init because the commands refer back to it for their usage text
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":   {"probe targets and print loss and latency statistics", runPing},
+		"trace":  {"trace the route to a host", runTrace},
+		"scan":   {"scan a host for open TCP ports", runScan},
+		"ifaces": {"list network interfaces and their addresses", runIfaces},
+		"routes": {"print the routing table", runRoutes},
+		"bgp":    {"look up BGP routes for a prefix", runBGP},
+		"pmtu":   {"find the path MTU to a host with DF-flagged pings", runPMTU},
+	}
+}
+
+// Run executes the subcommand name with args and returns the exit code
+func Run(name string, args []string, stdout, stderr io.Writer) int {
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "ipmaster: unknown command %q\n", name)
+		Usage(stderr)
+		return ExitUsage
+	}
+	return cmd.run(args, stdout, stderr)
+}
+
+// Usage describes the subcommands and exit codes
+func Usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: ipmaster [flags]                  start the TUI")
+	fmt.Fprintln(w, "       ipmaster <command> [flags] [args] run a single tool")
+	fmt.Fprintln(w, "\nCommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w, "\nRun ipmaster <command> -h for its flags. Every command accepts --json and --csv.")
+	fmt.Fprintf(w, "Exit codes: %d ok, %d check failed, %d usage error, %d tool error\n", ExitOK, ExitFailed, ExitUsage, ExitError)
+}
+
+// newFlagSet creates the flag set of a subcommand with its usage line
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ipmaster %s [flags] %s\n\n%s.\n\nFlags:\n", name, args, commands[name].summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args, returning ExitOK for -h and ExitUsage for errors
+// as the exit code to use when parsing did not succeed
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK, false
+	}
+	if err != nil {
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
+
+// format selects how results are printed
+type format struct {
+	json, csv bool
+}
+
+// addFormatFlags registers --json and --csv on fs
+func addFormatFlags(fs *flag.FlagSet) *format {
+	f := &format{}
+	fs.BoolVar(&f.json, "json", false, "print results as JSON")
+	fs.BoolVar(&f.csv, "csv", false, "print results as CSV")
+	return f
+}
+
+// text reports whether the human-readable format is selected
+func (f *format) text() bool {
+	return !f.json && !f.csv
+}
+
+func (f *format) validate() error {
+	if f.json && f.csv {
+		return fmt.Errorf("--json and --csv are mutually exclusive")
+	}
+	return nil
+}
+
+// familyFlag registers -family on fs
+func familyFlag(fs *flag.FlagSet) *string {
+	return fs.String("family", "any", "address family for hostnames: any, ip4 or ip6")
+}
+
+// parseFamily reads the value of a -family flag
+func parseFamily(s string) (resolve.Family, error) {
+	switch strings.ToLower(s) {
+	case "", "any", "ip":
+		return resolve.Any, nil
+	case "4", "ip4", "ipv4":
+		return resolve.IPv4, nil
+	case "6", "ip6", "ipv6":
+		return resolve.IPv6, nil
+	}
+	return resolve.Any, fmt.Errorf("invalid family %q, expected any, ip4 or ip6", s)
+}
+
+// sourceFlag registers -source on fs
+func sourceFlag(fs *flag.FlagSet) *string {
+	return fs.String("source", "", "interface name or local address to send from")
+}
+
+// parseSource reads the value of a -source flag and checks it exists
+func parseSource(s string) (ipinfo.Source, error) {
+	source := ipinfo.ParseSource(s)
+	return source, source.Check()
+}
+
+// dscpFlag registers -dscp on fs
+func dscpFlag(fs *flag.FlagSet) *string {
+	return fs.String("dscp", "", "DSCP class or number to mark probes with, e.g. ef, af41 or 46")
+}
+
+// parseDSCP reads the value of a -dscp flag, 0 when unset
+func parseDSCP(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return ping.ParseDSCP(s)
+}
+
+// usageError reports a command line problem and returns ExitUsage
+func usageError(stderr io.Writer, fs *flag.FlagSet, err error) int {
+	fmt.Fprintf(stderr, "ipmaster %s: %v\n", fs.Name(), err)
+	fs.Usage()
+	return ExitUsage
+}
+
+// toolError reports a failure of the tool itself and returns ExitError
+func toolError(stderr io.Writer, name string, err error) int {
+	fmt.Fprintf(stderr, "ipmaster %s: %v\n", name, err)
+	return ExitError
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeCSV(w io.Writer, header []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+	return cw.Error()
+}
+
+// msText formats milliseconds with two decimals
+func msText(ms float64) string {
+	return fmt.Sprintf("%.2f", ms)
+}
@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/a-tharva/ipmaster/bgp"
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/a-tharva/ipmaster/iptables"
+)
+
+func runIfaces(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("ifaces", "", stderr)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+
+	details, err := ipinfo.GetIpDetails()
+	if err != nil {
+		return toolError(stderr, "ifaces", err)
+	}
+
+	type iface struct {
+		Name  string   `json:"name"`
+		MTU   int      `json:"mtu"`
+		Flags string   `json:"flags"`
+		Addrs []string `json:"addrs"`
+	}
+	var ifaces []iface
+	for _, d := range details {
+		ifaces = append(ifaces, iface{Name: d.Name, MTU: d.MTU, Flags: d.Flags.String(), Addrs: d.IPs})
+	}
+
+	switch {
+	case out.json:
+		err = writeJSON(stdout, ifaces)
+	case out.csv:
+		var rows [][]string
+		for _, i := range ifaces {
+			rows = append(rows, []string{i.Name, strconv.Itoa(i.MTU), i.Flags, strings.Join(i.Addrs, " ")})
+		}
+		err = writeCSV(stdout, []string{"name", "mtu", "flags", "addrs"}, rows)
+	default:
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "INTERFACE\tMTU\tFLAGS\tADDRESSES")
+		for _, i := range ifaces {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", i.Name, i.MTU, i.Flags, strings.Join(i.Addrs, ", "))
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return toolError(stderr, "ifaces", err)
+	}
+	return ExitOK
+}
+
+func runRoutes(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("routes", "", stderr)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+
+	routes, err := iptables.Routes()
+	if err != nil {
+		return toolError(stderr, "routes", err)
+	}
+
+	switch {
+	case out.json:
+		err = writeJSON(stdout, routes)
+	case out.csv:
+		var rows [][]string
+		for _, r := range routes {
+			rows = append(rows, []string{r.Destination, r.Gateway, r.Netmask, r.Interface, r.Source, r.Metric})
+		}
+		err = writeCSV(stdout, []string{"destination", "gateway", "netmask", "interface", "source", "metric"}, rows)
+	default:
+		tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "DESTINATION\tGATEWAY\tNETMASK\tINTERFACE\tSOURCE\tMETRIC")
+		for _, r := range routes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Destination, dash(r.Gateway), dash(r.Netmask), dash(r.Interface), dash(r.Source), dash(r.Metric))
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return toolError(stderr, "routes", err)
+	}
+	return ExitOK
+}
+
+func runBGP(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("bgp", "prefix", stderr)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+	if fs.NArg() != 1 {
+		return usageError(stderr, fs, fmt.Errorf("expected one prefix, e.g. 8.8.8.0/24"))
+	}
+	prefix := fs.Arg(0)
+	if _, _, err := net.ParseCIDR(prefix); err != nil {
+		return usageError(stderr, fs, fmt.Errorf("invalid prefix %q", prefix))
+	}
+
+	lookup := bgp.NewBGP(prefix, nil, nil)
+	text, err := lookup.Lookup()
+	if err != nil {
+		return toolError(stderr, "bgp", err)
+	}
+
+	switch {
+	case out.json:
+		err = writeJSON(stdout, struct {
+			Prefix string      `json:"prefix"`
+			Source string      `json:"source"`
+			Routes []bgp.Route `json:"routes"`
+			Output string      `json:"output"`
+		}{prefix, lookup.Source, lookup.Routes, text})
+	case out.csv:
+		var rows [][]string
+		for _, r := range lookup.Routes {
+			rows = append(rows, []string{r.Prefix, strconv.Itoa(r.ASN), r.Description, r.Country})
+		}
+		err = writeCSV(stdout, []string{"prefix", "asn", "description", "country"}, rows)
+	default:
+		_, err = io.WriteString(stdout, text)
+	}
+	if err != nil {
+		return toolError(stderr, "bgp", err)
+	}
+	if lookup.Source == "bgpview.io" && len(lookup.Routes) == 0 {
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// dash shows empty table fields as -
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/httpprobe"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/a-tharva/ipmaster/resolve"
+)
+
+// pingSummary is the outcome of pinging one target
+type pingSummary struct {
+	Target   string  `json:"target"`
+	Host     string  `json:"host"`
+	Probe    string  `json:"probe"`
+	Addr     string  `json:"addr,omitempty"`
+	Sent     int     `json:"sent"`
+	Recv     int     `json:"recv"`
+	Loss     float64 `json:"loss"`
+	MinMs    float64 `json:"min_ms"`
+	AvgMs    float64 `json:"avg_ms"`
+	MaxMs    float64 `json:"max_ms"`
+	StdDevMs float64 `json:"stddev_ms"`
+	JitterMs float64 `json:"jitter_ms"`
+	Dup      int     `json:"duplicates"`
+	Reord    int     `json:"reordered"`
+	Late     int     `json:"late"`
+	Outages  int     `json:"outages"`
+	Longest  float64 `json:"longest_outage_s"`
+	Error    string  `json:"error,omitempty"` // Last error, if any probe failed
+}
+
+func runPing(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("ping", "target...", stderr)
+	count := fs.Int("c", 4, "probes per target")
+	interval := fs.Duration("i", time.Second, "time between probes of a target")
+	timeout := fs.Duration("W", 0, "probe timeout (default from config, 2s)")
+	size := fs.Int("s", 0, "ICMP packet size in bytes (default from config, 24)")
+	ttl := fs.Int("ttl", 0, "ICMP TTL (default from config, 64)")
+	group := fs.String("group", "", "also ping the targets of this saved group")
+	family := familyFlag(fs)
+	sourceText := sourceFlag(fs)
+	dscpText := dscpFlag(fs)
+	quiet := fs.Bool("q", false, "only print the summary")
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+	fam, err := parseFamily(*family)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	source, err := parseSource(*sourceText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	if *dscpText != "" {
+		if _, err := parseDSCP(*dscpText); err != nil {
+			return usageError(stderr, fs, err)
+		}
+	}
+	if *count < 1 {
+		return usageError(stderr, fs, fmt.Errorf("-c must be at least 1"))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Using default ping settings: %v", err)
+	}
+	var texts []string
+	if *group != "" {
+		g, ok := cfg.Group(*group)
+		if !ok {
+			return usageError(stderr, fs, fmt.Errorf("no group named %q", *group))
+		}
+		texts = g.Specs()
+	}
+	for _, arg := range fs.Args() {
+		texts = append(texts, ping.ParseIPs(arg)...)
+	}
+	if len(texts) == 0 {
+		return usageError(stderr, fs, fmt.Errorf("no targets given"))
+	}
+
+	var targets []ping.Target
+	for _, text := range texts {
+		spec, err := ping.ParseSpec(text)
+		if err == nil && !resolve.ValidHost(spec.Host) {
+			err = fmt.Errorf("invalid host: %s", spec.Host)
+		}
+		if err != nil {
+			return usageError(stderr, fs, err)
+		}
+		s := cfg.Settings(spec.String())
+		if *timeout > 0 {
+			s.Timeout = *timeout
+		}
+		if *size > 0 {
+			s.Size = *size
+		}
+		if *ttl > 0 {
+			s.TTL = *ttl
+		}
+		if !source.IsZero() {
+			s.Source = source.String()
+		}
+		if *dscpText != "" {
+			s.DSCP, _ = parseDSCP(*dscpText)
+		}
+		targets = append(targets, ping.Target{Spec: spec, Settings: s})
+	}
+
+	mode := ping.DetectMode()
+	var mu sync.Mutex
+	printResult := func(res ping.Result) {
+		if !out.text() || *quiet {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if res.Err != nil {
+			fmt.Fprintf(stdout, "%s (%s) seq=%d failed: %v\n", res.Target, res.Spec.Label(), res.Seq, res.Err)
+			return
+		}
+		fmt.Fprintf(stdout, "%s (%s) seq=%d rtt=%s ms%s\n", res.Target, res.Spec.Label(), res.Seq, msText(ms(res.RTT)), anomalyNote(res.Anomalies))
+	}
+
+	summaries := make([]pingSummary, len(targets))
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target ping.Target) {
+			defer wg.Done()
+			summaries[i] = pingTarget(target, *count, *interval, fam, mode, printResult)
+		}(i, target)
+	}
+	wg.Wait()
+
+	code, icmpFailed := ExitOK, false
+	for i, s := range summaries {
+		if s.Recv == 0 {
+			code = ExitFailed
+			probe := targets[i].Spec.Type
+			icmpFailed = icmpFailed || probe == ping.ICMP || probe == ""
+		}
+	}
+
+	switch {
+	case out.json:
+		err = writeJSON(stdout, summaries)
+	case out.csv:
+		var rows [][]string
+		for _, s := range summaries {
+			rows = append(rows, []string{s.Target, s.Probe, s.Addr, strconv.Itoa(s.Sent), strconv.Itoa(s.Recv),
+				fmt.Sprintf("%.1f", s.Loss), msText(s.MinMs), msText(s.AvgMs), msText(s.MaxMs), msText(s.StdDevMs), msText(s.JitterMs),
+				strconv.Itoa(s.Dup), strconv.Itoa(s.Reord), strconv.Itoa(s.Late),
+				strconv.Itoa(s.Outages), fmt.Sprintf("%.1f", s.Longest), s.Error})
+		}
+		err = writeCSV(stdout, []string{"target", "probe", "addr", "sent", "recv", "loss", "min_ms", "avg_ms", "max_ms", "stddev_ms", "jitter_ms", "duplicates", "reordered", "late", "outages", "longest_outage_s", "error"}, rows)
+	default:
+		for _, s := range summaries {
+			fmt.Fprintf(stdout, "\n--- %s (%s) ---\n", s.Target, s.Probe)
+			fmt.Fprintf(stdout, "%d sent, %d received, %.1f%% loss, %s\n", s.Sent, s.Recv, s.Loss, outageText(s))
+			if s.Recv > 0 {
+				fmt.Fprintf(stdout, "rtt min/avg/max/stddev/jitter = %s/%s/%s/%s/%s ms\n",
+					msText(s.MinMs), msText(s.AvgMs), msText(s.MaxMs), msText(s.StdDevMs), msText(s.JitterMs))
+			} else if s.Error != "" {
+				fmt.Fprintf(stdout, "last error: %s\n", s.Error)
+			}
+			if s.Dup > 0 || s.Reord > 0 || s.Late > 0 {
+				fmt.Fprintf(stdout, "%d duplicate, %d reordered, %d late replies\n", s.Dup, s.Reord, s.Late)
+			}
+		}
+	}
+	if err != nil {
+		return toolError(stderr, "ping", err)
+	}
+	// Without usable ICMP sockets a failed ICMP target says nothing about the host
+	if mode.Err != nil && icmpFailed {
+		return toolError(stderr, "ping", fmt.Errorf("ICMP mode: %s", mode))
+	}
+	return code
+}
+
+// pingTarget probes target count times, interval apart, and summarises the results
+func pingTarget(target ping.Target, count int, interval time.Duration, family resolve.Family, mode ping.Mode, onResult func(ping.Result)) pingSummary {
+	spec, s := target.Spec, target.Settings
+	summary := pingSummary{Target: spec.String(), Host: spec.Host, Probe: spec.Label()}
+	stats := ping.NewStats(count)
+	outages := ping.NewOutages(1)
+	var mu sync.Mutex // Guards stats and answered against late replies
+	answered := -1
+
+	var addr string
+	if spec.Type != ping.HTTP {
+		res, err := resolve.Lookup(spec.Host, family)
+		if err != nil {
+			summary.Sent, summary.Loss, summary.Error = count, 100, err.Error()
+			return summary
+		}
+		addr = res.Addr.String()
+		summary.Addr = addr
+	}
+
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(interval)
+		}
+		res := ping.Result{Target: summary.Target, Spec: spec, Addr: addr, Seq: seq}
+		if spec.Type == ping.HTTP {
+			var timing httpprobe.Timing
+			timing, res.Err = ping.ProbeHTTP(spec, s)
+			if host, _, err := net.SplitHostPort(timing.Addr); err == nil {
+				summary.Addr = host
+			}
+			if res.Err == nil {
+				res.RTT = timing.Total
+			}
+		} else {
+			res.RTT, res.Anomalies, res.Err = ping.ProbeSpec(spec, addr, s, mode.Privileged, func(a ping.Anomalies) {
+				mu.Lock()
+				defer mu.Unlock()
+				// Like the monitor, count late replies overtaken by a later probe as reordered
+				if answered > res.Seq {
+					a.Reordered += a.Late
+				}
+				stats.AddAnomalies(a)
+			})
+		}
+		res.Time = time.Now()
+		if res.Err != nil {
+			summary.Error = res.Err.Error()
+		}
+		mu.Lock()
+		if res.Err == nil {
+			answered = seq
+		}
+		stats.Add(res)
+		mu.Unlock()
+		outages.Add(res)
+		onResult(res)
+	}
+	if spec.Type == ping.ICMP || spec.Type == "" {
+		// Let replies to the last probes arrive late before summarizing
+		time.Sleep(ping.LateWindow)
+	}
+
+	mu.Lock()
+	st := stats.Session()
+	mu.Unlock()
+	summary.Sent, summary.Recv, summary.Loss = st.Sent, st.Recv, st.Loss
+	summary.MinMs, summary.AvgMs, summary.MaxMs = ms(st.MinRTT), ms(st.AvgRTT), ms(st.MaxRTT)
+	summary.StdDevMs, summary.JitterMs = ms(st.StdDevRTT), ms(st.Jitter)
+	summary.Dup, summary.Reord, summary.Late = st.Anomalies.Duplicates, st.Anomalies.Reordered, st.Anomalies.Late
+	summaryOutages := outages.Summary(time.Now())
+	summary.Outages, summary.Longest = summaryOutages.Count, summaryOutages.Longest.Seconds()
+	return summary
+}
+
+// anomalyNote formats the anomalies of a single probe for its result line
+func anomalyNote(a ping.Anomalies) string {
+	if !a.Any() {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", a)
+}
+
+// outageText formats the outage counts of a summary like ping.OutageSummary
+func outageText(s pingSummary) string {
+	return ping.OutageSummary{Count: s.Outages, Longest: time.Duration(s.Longest * float64(time.Second))}.String()
+}
+
+func ms(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
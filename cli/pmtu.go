@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/a-tharva/ipmaster/pmtu"
+	"github.com/a-tharva/ipmaster/resolve"
+)
+
+func runPMTU(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("pmtu", "host", stderr)
+	perHop := fs.Bool("per-hop", false, "also find the MTU up to every hop of the path")
+	maxHops := fs.Int("max-hops", 30, "maximum number of hops with -per-hop")
+	timeout := fs.Duration("timeout", 2*time.Second, "time to wait for the answer to each probe")
+	tries := fs.Int("tries", 2, "probes of one size before it counts as lost")
+	minMTU := fs.Int("min", 0, "exit 1 if the path MTU is below this size")
+	sourceText := sourceFlag(fs)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+	source, err := parseSource(*sourceText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	if fs.NArg() != 1 || !resolve.ValidHost(fs.Arg(0)) {
+		return usageError(stderr, fs, fmt.Errorf("expected one valid host"))
+	}
+
+	res, err := resolve.Lookup(fs.Arg(0), resolve.IPv4)
+	if err != nil {
+		return toolError(stderr, "pmtu", err)
+	}
+	discoverer, err := pmtu.NewDiscoverer(res.Addr.String(), nil, nil)
+	if err != nil {
+		return toolError(stderr, "pmtu", err)
+	}
+	discoverer.PerHop = *perHop
+	discoverer.MaxHops = *maxHops
+	discoverer.Timeout = *timeout
+	discoverer.Tries = *tries
+	discoverer.Source = source
+	if err := discoverer.Run(); err != nil {
+		return toolError(stderr, "pmtu", err)
+	}
+
+	r := discoverer.Result()
+	switch {
+	case out.json:
+		err = writeJSON(stdout, r)
+	case out.csv:
+		if *perHop {
+			var rows [][]string
+			for _, hop := range r.Hops {
+				rows = append(rows, []string{strconv.Itoa(hop.TTL), hop.IP, strconv.Itoa(hop.MTU), strconv.FormatBool(hop.Timeout)})
+			}
+			err = writeCSV(stdout, []string{"ttl", "ip", "mtu", "timeout"}, rows)
+			break
+		}
+		err = writeCSV(stdout, []string{"dest", "path_mtu", "interface", "interface_mtu", "probes", "frag_needed", "blackhole"},
+			[][]string{{r.Dest, strconv.Itoa(r.PathMTU), r.Interface, strconv.Itoa(r.InterfaceMTU),
+				strconv.Itoa(r.Probes), strconv.Itoa(len(r.FragNeeded)), strconv.FormatBool(r.Blackhole)}})
+	default:
+		fmt.Fprintf(stdout, "Path MTU to %s (%s): %s\n", res.Host, res.Addr, dash(mtuValue(r.PathMTU)))
+		if r.Interface != "" {
+			fmt.Fprintf(stdout, "Interface %s MTU: %d\n", r.Interface, r.InterfaceMTU)
+		}
+		for _, hop := range r.Hops {
+			if hop.Timeout {
+				fmt.Fprintf(stdout, "%2d  *\n", hop.TTL)
+				continue
+			}
+			fmt.Fprintf(stdout, "%2d  %s  %d\n", hop.TTL, hop.IP, hop.MTU)
+		}
+		for _, frag := range r.FragNeeded {
+			hop := ""
+			if frag.TTL > 0 {
+				hop = fmt.Sprintf(" (hop %d)", frag.TTL)
+			}
+			fmt.Fprintf(stdout, "Fragmentation needed from %s%s for %d bytes, next-hop MTU %s\n", frag.From, hop, frag.Size, dash(mtuValue(frag.MTU)))
+		}
+		if r.Unreachable != "" {
+			fmt.Fprintln(stdout, r.Unreachable)
+		}
+		if r.Blackhole {
+			fmt.Fprintln(stdout, "Some probes vanished without a Fragmentation needed message: possible MTU blackhole")
+		}
+	}
+	if err != nil {
+		return toolError(stderr, "pmtu", err)
+	}
+	if r.PathMTU == 0 || r.PathMTU < *minMTU {
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// mtuValue formats a size, "" when unknown
+func mtuValue(mtu int) string {
+	if mtu == 0 {
+		return ""
+	}
+	return strconv.Itoa(mtu)
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ports"
+	"github.com/a-tharva/ipmaster/resolve"
+	"github.com/a-tharva/ipmaster/tracert"
+)
+
+func runTrace(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("trace", "host", stderr)
+	maxHops := fs.Int("max-hops", 30, "maximum number of hops")
+	timeout := fs.Duration("timeout", 5*time.Second, "time to wait for each probe")
+	probes := fs.Int("probes", 3, "probes per hop")
+	methodText := fs.String("method", "icmp", "probe method: icmp, udp or tcp (SYN)")
+	port := fs.Int("port", 0, "UDP base port or TCP port (default 33434 for udp, 80 for tcp)")
+	paris := fs.Bool("paris", false, "keep the flow of all probes the same so load balancers send them one way")
+	geo := fs.Bool("geo", true, "look up the location of each hop on ipinfo.io")
+	family := familyFlag(fs)
+	sourceText := sourceFlag(fs)
+	dscpText := dscpFlag(fs)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+	fam, err := parseFamily(*family)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	source, err := parseSource(*sourceText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	dscp, err := parseDSCP(*dscpText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	method, err := tracert.ParseMethod(*methodText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	if *port < 0 || *port > 65535 {
+		return usageError(stderr, fs, fmt.Errorf("invalid port %d", *port))
+	}
+	if fs.NArg() != 1 || !resolve.ValidHost(fs.Arg(0)) {
+		return usageError(stderr, fs, fmt.Errorf("expected one valid host"))
+	}
+
+	res, err := resolve.Lookup(fs.Arg(0), fam)
+	if err != nil {
+		return toolError(stderr, "trace", err)
+	}
+	tracer, err := tracert.NewTracer(res.Addr.String())
+	if err != nil {
+		return toolError(stderr, "trace", err)
+	}
+	tracer.MaxHops = *maxHops
+	tracer.Timeout = *timeout
+	tracer.Probes = *probes
+	tracer.Method = method
+	tracer.Port = *port
+	tracer.Paris = *paris
+	tracer.Locate = *geo
+	tracer.Source = source
+	tracer.DSCP = dscp
+	if out.text() {
+		fmt.Fprintf(stdout, "Traceroute to %s (%s), %d hops max, %s probes\n", res.Host, res.Addr, *maxHops, method)
+	}
+	// Text output is printed as the hops arrive
+	var onHop func(tracert.Hop)
+	if out.text() {
+		onHop = func(hop tracert.Hop) {
+			if hop.Timeout {
+				fmt.Fprintf(stdout, "%2d  %s\n", hop.TTL, hop.ProbesText())
+				return
+			}
+			location := ""
+			if hop.Location != "" {
+				location = fmt.Sprintf(" (%s)", hop.Location)
+			}
+			fmt.Fprintf(stdout, "%2d  %s%s  %s\n", hop.TTL, hop.IP, location, hop.ProbesText())
+		}
+	}
+	if err := tracer.Run(context.Background(), onHop); err != nil {
+		return toolError(stderr, "trace", err)
+	}
+
+	hops := tracer.Hops()
+	switch {
+	case out.json:
+		err = writeJSON(stdout, struct {
+			Target  string        `json:"target"`
+			Addr    string        `json:"addr"`
+			Reached bool          `json:"reached"`
+			Hops    []tracert.Hop `json:"hops"`
+		}{res.Host, res.Addr.String(), tracer.Reached(), hops})
+	case out.csv:
+		var rows [][]string
+		for _, hop := range hops {
+			var rtts []string
+			for _, r := range hop.Replies {
+				if r.Timeout {
+					rtts = append(rtts, "*")
+				} else {
+					rtts = append(rtts, msText(r.RTT))
+				}
+			}
+			rows = append(rows, []string{strconv.Itoa(hop.TTL), hop.IP, strings.Join(hop.IPs, " "), msText(hop.RTT), strings.Join(rtts, " "),
+				strconv.Itoa(hop.Type), strconv.Itoa(hop.Code), hop.Location, strconv.FormatBool(hop.Timeout)})
+		}
+		err = writeCSV(stdout, []string{"ttl", "ip", "responders", "rtt_ms", "probe_rtts_ms", "icmp_type", "icmp_code", "location", "timeout"}, rows)
+	}
+	if err != nil {
+		return toolError(stderr, "trace", err)
+	}
+	if !tracer.Reached() {
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+// portState is the result of scanning one port
+type portState struct {
+	Port int  `json:"port"`
+	Open bool `json:"open"`
+}
+
+func runScan(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("scan", "host", stderr)
+	portList := fs.String("ports", "", "ports to check, e.g. 22,80,8000-8100; every one must be open (default 1-1024, any open)")
+	timeout := fs.Duration("timeout", 2*time.Second, "connection timeout per port")
+	family := familyFlag(fs)
+	sourceText := sourceFlag(fs)
+	out := addFormatFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := out.validate(); err != nil {
+		return usageError(stderr, fs, err)
+	}
+	fam, err := parseFamily(*family)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	source, err := parseSource(*sourceText)
+	if err != nil {
+		return usageError(stderr, fs, err)
+	}
+	if fs.NArg() != 1 || !resolve.ValidHost(fs.Arg(0)) {
+		return usageError(stderr, fs, fmt.Errorf("expected one valid host"))
+	}
+	var explicit []int
+	if *portList != "" {
+		if explicit, err = parsePorts(*portList); err != nil {
+			return usageError(stderr, fs, err)
+		}
+	}
+
+	res, err := resolve.Lookup(fs.Arg(0), fam)
+	if err != nil {
+		return toolError(stderr, "scan", err)
+	}
+	scanner, err := ports.NewPortScanner(res.Addr.String(), nil, nil)
+	if err != nil {
+		return toolError(stderr, "scan", err)
+	}
+	scanner.Ports = explicit
+	scanner.Timeout = *timeout
+	scanner.Source = source
+	if err := scanner.ScanPorts(); err != nil {
+		return toolError(stderr, "scan", err)
+	}
+
+	open := make(map[int]bool)
+	for _, p := range scanner.OpenPorts() {
+		open[p] = true
+	}
+	// With an explicit list every port is reported, otherwise only open ones
+	states := []portState{}
+	code := ExitOK
+	for _, p := range scanner.ScanList() {
+		if open[p] || len(explicit) > 0 {
+			states = append(states, portState{Port: p, Open: open[p]})
+		}
+		if len(explicit) > 0 && !open[p] {
+			code = ExitFailed
+		}
+	}
+	if len(explicit) == 0 && len(open) == 0 {
+		code = ExitFailed
+	}
+
+	switch {
+	case out.json:
+		err = writeJSON(stdout, struct {
+			Target string      `json:"target"`
+			Addr   string      `json:"addr"`
+			Ports  []portState `json:"ports"`
+		}{res.Host, res.Addr.String(), states})
+	case out.csv:
+		var rows [][]string
+		for _, s := range states {
+			rows = append(rows, []string{strconv.Itoa(s.Port), stateText(s.Open)})
+		}
+		err = writeCSV(stdout, []string{"port", "state"}, rows)
+	default:
+		fmt.Fprintf(stdout, "Scanned %d ports on %s (%s)\n", len(scanner.ScanList()), res.Host, res.Addr)
+		if len(states) == 0 {
+			fmt.Fprintln(stdout, "No open ports found")
+		}
+		for _, s := range states {
+			fmt.Fprintf(stdout, "%5d/tcp  %s\n", s.Port, stateText(s.Open))
+		}
+	}
+	if err != nil {
+		return toolError(stderr, "scan", err)
+	}
+	return code
+}
+
+func stateText(open bool) string {
+	if open {
+		return "open"
+	}
+	return "closed"
+}
+
+// parsePorts reads a list such as "22,80,8000-8100"
+func parsePorts(s string) ([]int, error) {
+	var list []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(first)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(last)
+		}
+		if err != nil || start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("invalid port or range %q", part)
+		}
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				list = append(list, p)
+			}
+		}
+	}
+	return list, nil
+}
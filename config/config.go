@@ -0,0 +1,268 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/a-tharva/ipmaster/alert"
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// Duration is a time.Duration stored as text such as "2s" or "150ms"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Probe holds probe parameters and latency thresholds. Zero fields inherit
+// from the level above: target overrides fall back to the defaults, and
+// defaults fall back to ping.DefaultSettings.
+type Probe struct {
+	Interval Duration `json:"interval,omitempty"`
+	Timeout  Duration `json:"timeout,omitempty"`
+	Size     int      `json:"size,omitempty"`
+	TTL      int      `json:"ttl,omitempty"`
+	Count    int      `json:"count,omitempty"`
+	Warn     Duration `json:"warn,omitempty"`    // RTT from which a reply is shown yellow
+	Crit     Duration `json:"crit,omitempty"`    // RTT from which a reply is shown red
+	Payload  string   `json:"payload,omitempty"` // UDP payload, "hex:" prefix for binary
+	Expect   string   `json:"expect,omitempty"`  // Data a UDP reply or HTTP body must contain
+	Source   string   `json:"source,omitempty"`  // Interface or local address to send from
+	DSCP     string   `json:"dscp,omitempty"`    // DSCP class or number, see ping.ParseDSCP
+
+	Headers      map[string]string `json:"headers,omitempty"` // Extra HTTP request headers
+	ExpectStatus int               `json:"expect_status,omitempty"`
+}
+
+// Merge returns p with every non-zero field of over applied
+func (p Probe) Merge(over Probe) Probe {
+	if over.Interval != 0 {
+		p.Interval = over.Interval
+	}
+	if over.Timeout != 0 {
+		p.Timeout = over.Timeout
+	}
+	if over.Size != 0 {
+		p.Size = over.Size
+	}
+	if over.TTL != 0 {
+		p.TTL = over.TTL
+	}
+	if over.Count != 0 {
+		p.Count = over.Count
+	}
+	if over.Warn != 0 {
+		p.Warn = over.Warn
+	}
+	if over.Crit != 0 {
+		p.Crit = over.Crit
+	}
+	if over.Payload != "" {
+		p.Payload = over.Payload
+	}
+	if over.Expect != "" {
+		p.Expect = over.Expect
+	}
+	if over.Source != "" {
+		p.Source = over.Source
+	}
+	if over.DSCP != "" {
+		p.DSCP = over.DSCP
+	}
+	if len(over.Headers) > 0 {
+		p.Headers = over.Headers
+	}
+	if over.ExpectStatus != 0 {
+		p.ExpectStatus = over.ExpectStatus
+	}
+	return p
+}
+
+// Apply returns s with every non-zero field of p applied
+func (p Probe) Apply(s ping.Settings) ping.Settings {
+	if p.Interval != 0 {
+		s.Interval = time.Duration(p.Interval)
+	}
+	if p.Timeout != 0 {
+		s.Timeout = time.Duration(p.Timeout)
+	}
+	if p.Size != 0 {
+		s.Size = p.Size
+	}
+	if p.TTL != 0 {
+		s.TTL = p.TTL
+	}
+	if p.Count != 0 {
+		s.Count = p.Count
+	}
+	if p.Warn != 0 {
+		s.Warn = time.Duration(p.Warn)
+	}
+	if p.Crit != 0 {
+		s.Crit = time.Duration(p.Crit)
+	}
+	if p.Payload != "" {
+		s.Payload = p.Payload
+	}
+	if p.Expect != "" {
+		s.Expect = p.Expect
+	}
+	if p.Source != "" {
+		s.Source = p.Source
+	}
+	if dscp, err := ping.ParseDSCP(p.DSCP); p.DSCP != "" && err == nil {
+		s.DSCP = dscp
+	}
+	if len(p.Headers) > 0 {
+		s.Headers = p.Headers
+	}
+	if p.ExpectStatus != 0 {
+		s.ExpectStatus = p.ExpectStatus
+	}
+	return s
+}
+
+// Alerts configures state-change alerting on the Ping page. Zero fields
+// fall back to alert.DefaultRules.
+type Alerts struct {
+	DownAfter int      `json:"down_after,omitempty"`
+	UpAfter   int      `json:"up_after,omitempty"`
+	Degraded  Duration `json:"degraded,omitempty"` // Recent average RTT above which a target is degraded
+	Quiet     bool     `json:"quiet,omitempty"`    // Do not ring the terminal bell
+	Commands  []string `json:"commands,omitempty"` // Shell commands run with IPMASTER_* variables
+	Webhooks  []string `json:"webhooks,omitempty"` // URLs the event is POSTed to as JSON
+}
+
+// Rules returns the transition rules described by a
+func (a Alerts) Rules() alert.Rules {
+	rules := alert.DefaultRules()
+	if a.DownAfter != 0 {
+		rules.DownAfter = a.DownAfter
+	}
+	if a.UpAfter != 0 {
+		rules.UpAfter = a.UpAfter
+	}
+	rules.Degraded = time.Duration(a.Degraded)
+	return rules
+}
+
+// Actions returns the configured commands and webhooks
+func (a Alerts) Actions() []alert.Action {
+	var actions []alert.Action
+	for _, cmd := range a.Commands {
+		actions = append(actions, alert.Command(cmd))
+	}
+	for _, url := range a.Webhooks {
+		actions = append(actions, alert.Webhook(url))
+	}
+	return actions
+}
+
+// Default retention of the ping history store
+const (
+	DefaultHistoryMaxAge   = 30 * 24 * time.Hour
+	DefaultHistorySessions = 100
+)
+
+// History configures the on-disk ping history. Zero limits fall back to
+// DefaultHistoryMaxAge and DefaultHistorySessions; negative ones disable the limit.
+type History struct {
+	Disabled    bool     `json:"disabled,omitempty"`
+	MaxAge      Duration `json:"max_age,omitempty"`      // Sessions started longer ago are deleted
+	MaxSessions int      `json:"max_sessions,omitempty"` // Number of newest sessions kept
+}
+
+// Retention returns the effective age and session count limits, zero meaning unlimited
+func (h History) Retention() (time.Duration, int) {
+	maxAge, maxSessions := time.Duration(h.MaxAge), h.MaxSessions
+	if maxAge == 0 {
+		maxAge = DefaultHistoryMaxAge
+	}
+	if maxSessions == 0 {
+		maxSessions = DefaultHistorySessions
+	}
+	return max(maxAge, 0), max(maxSessions, 0)
+}
+
+// Config is the persisted IPmaster configuration
+type Config struct {
+	Defaults Probe            `json:"defaults"`
+	Targets  map[string]Probe `json:"targets,omitempty"` // Per-target overrides keyed by target spec
+	Alerts   Alerts           `json:"alerts"`
+	History  History          `json:"history"`
+	Groups   []Group          `json:"groups,omitempty"` // Saved target groups for the Ping page
+}
+
+// Settings returns the effective probe settings for target
+func (c *Config) Settings(target string) ping.Settings {
+	return c.Defaults.Merge(c.Targets[target]).Apply(ping.DefaultSettings())
+}
+
+// Path returns the location of the configuration file
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %w", err)
+	}
+	return filepath.Join(dir, "ipmaster", "config.json"), nil
+}
+
+// Load reads the configuration file, returning an empty configuration if it
+// does not exist yet
+func Load() (*Config, error) {
+	cfg := &Config{Targets: make(map[string]Probe)}
+	path, err := Path()
+	if err != nil {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if cfg.Targets == nil {
+		cfg.Targets = make(map[string]Probe)
+	}
+	return cfg, nil
+}
+
+// Save writes the configuration file, creating its directory if needed
+func (c *Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	return os.Rename(tmp, path)
+}
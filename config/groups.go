@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// Group is a named list of targets that can be monitored together
+type Group struct {
+	Name    string        `json:"name"`
+	Targets []GroupTarget `json:"targets"`
+}
+
+// GroupTarget is a target spec with an optional label shown instead of the host
+type GroupTarget struct {
+	Target string `json:"target"`
+	Label  string `json:"label,omitempty"`
+}
+
+// Specs returns the target specs of g, in order
+func (g Group) Specs() []string {
+	var specs []string
+	for _, t := range g.Targets {
+		specs = append(specs, t.Target)
+	}
+	return specs
+}
+
+// Group returns the group called name
+func (c *Config) Group(name string) (Group, bool) {
+	for _, g := range c.Groups {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return Group{}, false
+}
+
+// SetGroup adds g, replacing any group with the same name
+func (c *Config) SetGroup(g Group) {
+	for i := range c.Groups {
+		if c.Groups[i].Name == g.Name {
+			c.Groups[i] = g
+			return
+		}
+	}
+	c.Groups = append(c.Groups, g)
+}
+
+// DeleteGroup removes the group called name
+func (c *Config) DeleteGroup(name string) {
+	for i := range c.Groups {
+		if c.Groups[i].Name == name {
+			c.Groups = append(c.Groups[:i], c.Groups[i+1:]...)
+			return
+		}
+	}
+}
+
+// Label returns the label given to target by any group, or "" if none
+func (c *Config) Label(target string) string {
+	for _, g := range c.Groups {
+		for _, t := range g.Targets {
+			if t.Target == target && t.Label != "" {
+				return t.Label
+			}
+		}
+	}
+	return ""
+}
+
+// GroupOf returns the name of the first group containing target, or "" if none
+func (c *Config) GroupOf(target string) string {
+	for _, g := range c.Groups {
+		for _, t := range g.Targets {
+			if t.Target == target {
+				return g.Name
+			}
+		}
+	}
+	return ""
+}
+
+// ParseGroupTargets reads one target per line, optionally followed by a
+// label: "target,label" as CSV, or "target label" as plain text. Blank
+// lines, lines starting with # and a "target" or "host" header are skipped.
+// Targets are normalised to the form produced by ping.Spec.String.
+func ParseGroupTargets(r io.Reader) ([]GroupTarget, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	var targets []GroupTarget
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		target := strings.TrimSpace(record[0])
+		var label string
+		if len(record) > 1 {
+			label = strings.TrimSpace(strings.Join(record[1:], ","))
+		} else if host, rest, ok := strings.Cut(target, " "); ok {
+			target, label = host, strings.TrimSpace(rest)
+		}
+		if target == "" {
+			continue
+		}
+		if lower := strings.ToLower(target); len(targets) == 0 && (lower == "target" || lower == "host") {
+			continue
+		}
+
+		spec, err := ping.ParseSpec(target)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		targets = append(targets, GroupTarget{Target: spec.String(), Label: label})
+	}
+	return targets, nil
+}
+
+// ImportGroupTargets reads targets from a text or CSV file, see ParseGroupTargets
+func ImportGroupTargets(path string) ([]GroupTarget, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	targets, err := ParseGroupTargets(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// FormatGroupTargets writes targets as CSV lines accepted by ParseGroupTargets
+func FormatGroupTargets(targets []GroupTarget) string {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	for _, t := range targets {
+		if t.Label == "" {
+			w.Write([]string{t.Target})
+		} else {
+			w.Write([]string{t.Target, t.Label})
+		}
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/a-tharva/ipmaster/alert"
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/metrics"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/a-tharva/ipmaster/resolve"
+)
+
+// runHeadless monitors the targets of group, or the comma-separated
+// targets, without the TUI and serves their metrics on metricsAddr until
+// interrupted
+func runHeadless(targets, group, metricsAddr string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Using default ping settings: %v", err)
+	}
+
+	pingTargets, labels, err := headlessTargets(cfg, targets, group)
+	if err != nil {
+		return err
+	}
+	exporter := metrics.NewExporter()
+	exporter.SetTargets(labels)
+
+	monitor := ping.NewMonitor()
+	if monitor.Mode.Err != nil {
+		fmt.Fprintf(os.Stderr, "ICMP mode: %s\n", monitor.Mode)
+	}
+	tracker := alert.NewTracker(cfg.Alerts.Rules())
+	actions := cfg.Alerts.Actions()
+	monitor.Subscribe(exporter.Observe)
+	monitor.Subscribe(func(res ping.Result) {
+		_, window := monitor.Statistics(res.Target)
+		if ev, changed := tracker.Observe(res, window); changed {
+			log.Printf("Alert: %s", ev)
+			alert.Dispatch(actions, ev)
+		}
+	})
+
+	server, err := exporter.Serve(metricsAddr)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Monitoring %d targets, metrics on http://%s/metrics\n", len(pingTargets), metricsAddr)
+
+	monitor.SetTargets(pingTargets)
+	monitor.Start()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	<-interrupt
+
+	monitor.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
+// headlessTargets returns the targets of group followed by the
+// comma-separated targets, with the labels of their metric series keyed by
+// spec string. Targets listed twice are monitored once.
+func headlessTargets(cfg *config.Config, targets, group string) ([]ping.Target, map[string]metrics.Labels, error) {
+	// Group targets carry the group's name and label, -targets entries those
+	// of the first saved group containing them
+	type entry struct {
+		text, group, label string
+	}
+	var entries []entry
+	if group != "" {
+		g, ok := cfg.Group(group)
+		if !ok {
+			return nil, nil, fmt.Errorf("no group named %q in %s", group, configPath())
+		}
+		for _, t := range g.Targets {
+			entries = append(entries, entry{text: t.Target, group: group, label: t.Label})
+		}
+	}
+	for _, text := range ping.ParseIPs(targets) {
+		if text != "" {
+			entries = append(entries, entry{text: text})
+		}
+	}
+	if len(entries) == 0 {
+		return nil, nil, fmt.Errorf("no targets given, use -targets or -group")
+	}
+
+	labels := make(map[string]metrics.Labels)
+	var pingTargets []ping.Target
+	for _, e := range entries {
+		spec, err := ping.ParseSpec(strings.TrimSpace(e.text))
+		if err == nil && !resolve.ValidHost(spec.Host) {
+			err = fmt.Errorf("invalid host: %s", spec.Host)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		key := spec.String()
+		if _, dup := labels[key]; dup {
+			continue
+		}
+		if e.group == "" {
+			e.group, e.label = cfg.GroupOf(key), cfg.Label(key)
+		}
+		labels[key] = metrics.Labels{Group: e.group, Label: e.label}
+		pingTargets = append(pingTargets, ping.Target{Spec: spec, Settings: cfg.Settings(key)})
+	}
+	return pingTargets, labels, nil
+}
+
+// configPath names the configuration file for error messages
+func configPath() string {
+	path, err := config.Path()
+	if err != nil {
+		return "the configuration file"
+	}
+	return path
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/metrics"
+)
+
+func TestHeadlessTargets(t *testing.T) {
+	cfg := &config.Config{Groups: []config.Group{
+		{
+			Name: "dns",
+			Targets: []config.GroupTarget{
+				{Target: "8.8.8.8", Label: "Google"},
+				{Target: "1.1.1.1:tcp/53"},
+			},
+		},
+		{
+			Name: "web",
+			Targets: []config.GroupTarget{
+				{Target: " 9.9.9.9:TCP/443", Label: "Quad9"}, // Hand-edited, not normalized
+				{Target: "example.com:tcp/443", Label: "Example"},
+			},
+		},
+	}}
+
+	tests := []struct {
+		name       string
+		targets    string
+		group      string
+		wantSpecs  []string
+		wantLabels map[string]metrics.Labels
+	}{
+		{
+			name:      "group without targets",
+			group:     "dns",
+			wantSpecs: []string{"8.8.8.8", "1.1.1.1:tcp/53"},
+			wantLabels: map[string]metrics.Labels{
+				"8.8.8.8":        {Group: "dns", Label: "Google"},
+				"1.1.1.1:tcp/53": {Group: "dns"},
+			},
+		},
+		{
+			name:      "targets without group",
+			targets:   "9.9.9.9, ,8.8.8.8,",
+			wantSpecs: []string{"9.9.9.9", "8.8.8.8"},
+			wantLabels: map[string]metrics.Labels{
+				"9.9.9.9": {},
+				"8.8.8.8": {Group: "dns", Label: "Google"},
+			},
+		},
+		{
+			name:      "group and targets",
+			targets:   "9.9.9.9, example.com:TCP/443, 8.8.8.8",
+			group:     "dns",
+			wantSpecs: []string{"8.8.8.8", "1.1.1.1:tcp/53", "9.9.9.9", "example.com:tcp/443"},
+			wantLabels: map[string]metrics.Labels{
+				"8.8.8.8":             {Group: "dns", Label: "Google"},
+				"1.1.1.1:tcp/53":      {Group: "dns"},
+				"9.9.9.9":             {},
+				"example.com:tcp/443": {Group: "web", Label: "Example"},
+			},
+		},
+		{
+			name:      "group not normalized",
+			group:     "web",
+			wantSpecs: []string{"9.9.9.9:tcp/443", "example.com:tcp/443"},
+			wantLabels: map[string]metrics.Labels{
+				"9.9.9.9:tcp/443":     {Group: "web", Label: "Quad9"},
+				"example.com:tcp/443": {Group: "web", Label: "Example"},
+			},
+		},
+		{
+			name:      "targets not normalized",
+			targets:   " 1.1.1.1:TCP/53 ",
+			wantSpecs: []string{"1.1.1.1:tcp/53"},
+			wantLabels: map[string]metrics.Labels{
+				"1.1.1.1:tcp/53": {Group: "dns"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, labels, err := headlessTargets(cfg, tt.targets, tt.group)
+			if err != nil {
+				t.Fatalf("headlessTargets failed: %v", err)
+			}
+			if len(targets) != len(tt.wantSpecs) {
+				t.Fatalf("got %d targets, want %d", len(targets), len(tt.wantSpecs))
+			}
+			for i, target := range targets {
+				if got := target.Spec.String(); got != tt.wantSpecs[i] {
+					t.Errorf("target %d = %s, want %s", i, got, tt.wantSpecs[i])
+				}
+			}
+			if len(labels) != len(tt.wantLabels) {
+				t.Errorf("got labels %v, want %v", labels, tt.wantLabels)
+			}
+			for target, want := range tt.wantLabels {
+				if got := labels[target]; got != want {
+					t.Errorf("labels of %s = %+v, want %+v", target, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHeadlessTargetsInvalid(t *testing.T) {
+	cfg := &config.Config{}
+	for _, tt := range []struct{ targets, group string }{
+		{"", ""},
+		{" , ", ""},
+		{"", "missing"},
+		{"bad host!", ""},
+	} {
+		if _, _, err := headlessTargets(cfg, tt.targets, tt.group); err == nil {
+			t.Errorf("headlessTargets(%q, %q) succeeded, want an error", tt.targets, tt.group)
+		}
+	}
+}
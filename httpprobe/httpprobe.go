@@ -0,0 +1,173 @@
+package httpprobe
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// maxMatchBody bounds how much of the body is kept for ExpectBody matching
+const maxMatchBody = 1 << 20
+
+// Options configures an HTTP probe
+type Options struct {
+	Method       string
+	Headers      http.Header
+	Timeout      time.Duration
+	ExpectStatus int         // Required status code; any status below 400 when zero
+	ExpectBody   string      // Text the body must contain, unchecked when empty
+	Insecure     bool        // Skip TLS certificate verification
+	Dialer       *net.Dialer // Opens connections, e.g. from a source address; a plain dialer if nil
+}
+
+// DefaultOptions returns a GET with a 10 second timeout
+func DefaultOptions() Options {
+	return Options{Method: http.MethodGet, Timeout: 10 * time.Second}
+}
+
+// Timing is the breakdown of a single HTTP request. Phases that did not
+// happen, such as DNS for an IP literal or TLS for plain HTTP, are zero.
+type Timing struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration // From sending the request to the first response byte
+	Total     time.Duration // From start until the body was read completely
+	Status    int
+	Size      int64  // Response body size in bytes
+	Addr      string // Remote address the request was sent to
+}
+
+// Probe issues one request to url over a fresh connection and times each phase
+func Probe(url string, opts Options) (Timing, error) {
+	// The trace callbacks run on the transport's goroutines, concurrently
+	// for the dials of both address families and possibly after client.Do
+	// gave up, so they and the final copy hold mu
+	var mu sync.Mutex
+	var t Timing
+	var start, dnsStart, tlsStart, wrote time.Time
+	connStart := make(map[string]time.Time)
+	timing := func() Timing {
+		mu.Lock()
+		defer mu.Unlock()
+		return t
+	}
+	locked := func(fn func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		fn()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { locked(func() { dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { locked(func() { t.DNS = time.Since(dnsStart) }) },
+		ConnectStart: func(_, addr string) {
+			locked(func() { connStart[addr] = time.Now() })
+		},
+		ConnectDone: func(_, addr string, err error) {
+			locked(func() {
+				// Only the connection the request is sent on counts
+				if err == nil && t.Addr == "" {
+					t.Connect = time.Since(connStart[addr])
+					t.Addr = addr
+				}
+			})
+		},
+		TLSHandshakeStart: func() { locked(func() { tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			locked(func() { t.TLS = time.Since(tlsStart) })
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { locked(func() { wrote = time.Now() }) },
+		GotFirstResponseByte: func() { locked(func() { t.FirstByte = time.Since(wrote) }) },
+	}
+
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return Timing{}, fmt.Errorf("invalid request: %w", err)
+	}
+	for name, values := range opts.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if host := opts.Headers.Get("Host"); host != "" {
+		req.Host = host
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{Timeout: opts.Timeout}
+	}
+	client := &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DialContext:       dialer.DialContext,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: opts.Insecure},
+			DisableKeepAlives: true,
+		},
+		// Time the URL as given rather than wherever it redirects to
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		res := timing()
+		res.Total = time.Since(start)
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	var body bytes.Buffer
+	n, err := io.Copy(&limitedBuffer{&body, maxMatchBody}, resp.Body)
+	res := timing()
+	res.Total = time.Since(start)
+	res.Status = resp.StatusCode
+	res.Size = n
+	if err != nil {
+		return res, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	if opts.ExpectStatus != 0 && resp.StatusCode != opts.ExpectStatus {
+		return res, fmt.Errorf("status %d, expected %d", resp.StatusCode, opts.ExpectStatus)
+	}
+	if opts.ExpectStatus == 0 && resp.StatusCode >= 400 {
+		return res, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if opts.ExpectBody != "" && !bytes.Contains(body.Bytes(), []byte(opts.ExpectBody)) {
+		return res, fmt.Errorf("body does not contain %q", opts.ExpectBody)
+	}
+	return res, nil
+}
+
+// limitedBuffer keeps at most max bytes but reports every write as complete
+// so the rest of the body is still counted
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	max int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.max - l.buf.Len(); room > 0 {
+		if len(p) > room {
+			l.buf.Write(p[:room])
+		} else {
+			l.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,132 @@
+package httpprobe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newServer serves body with status on /, and on /headers echoes the
+// X-Probe header it received
+func newServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+	mux.HandleFunc("/headers", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "x-probe=%s host=%s", r.Header.Get("X-Probe"), r.Host)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProbe(t *testing.T) {
+	body := strings.Repeat("ipmaster ", 100)
+	server := newServer(t, http.StatusOK, body)
+
+	timing, err := Probe(server.URL, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Probe failed: %v", err)
+	}
+	if timing.Status != http.StatusOK {
+		t.Errorf("Status = %d, want %d", timing.Status, http.StatusOK)
+	}
+	if timing.Size != int64(len(body)) {
+		t.Errorf("Size = %d, want %d", timing.Size, len(body))
+	}
+	if timing.FirstByte <= 0 || timing.FirstByte > timing.Total {
+		t.Errorf("FirstByte = %v, want between 0 and Total %v", timing.FirstByte, timing.Total)
+	}
+	if timing.Connect <= 0 || timing.Addr != server.Listener.Addr().String() {
+		t.Errorf("Connect = %v to %q, want a connection to %s", timing.Connect, timing.Addr, server.Listener.Addr())
+	}
+	if timing.DNS != 0 || timing.TLS != 0 {
+		t.Errorf("DNS = %v, TLS = %v, want zero for plain HTTP to an IP", timing.DNS, timing.TLS)
+	}
+}
+
+func TestProbeExpectations(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		opts    Options
+		wantErr string
+	}{
+		{name: "any success", status: http.StatusNoContent},
+		{name: "redirect not followed", status: http.StatusFound},
+		{name: "error status", status: http.StatusServiceUnavailable, wantErr: "status 503"},
+		{name: "expected status", status: http.StatusNotFound, opts: Options{ExpectStatus: http.StatusNotFound}},
+		{name: "status mismatch", status: http.StatusOK, opts: Options{ExpectStatus: http.StatusCreated},
+			wantErr: "status 200, expected 201"},
+		{name: "body matches", status: http.StatusOK, opts: Options{ExpectBody: "healthy"}},
+		{name: "body mismatch", status: http.StatusOK, opts: Options{ExpectBody: "degraded"},
+			wantErr: `body does not contain "degraded"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t, tt.status, "service healthy")
+			tt.opts.Timeout = 5 * time.Second
+
+			timing, err := Probe(server.URL, tt.opts)
+			if timing.Status != tt.status {
+				t.Errorf("Status = %d, want %d", timing.Status, tt.status)
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Probe failed: %v", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Errorf("Probe error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProbeHeaders(t *testing.T) {
+	server := newServer(t, http.StatusOK, "")
+	opts := DefaultOptions()
+	opts.Headers = http.Header{"X-Probe": {"ipmaster"}, "Host": {"status.example"}}
+	opts.ExpectBody = "x-probe=ipmaster host=status.example"
+
+	if _, err := Probe(server.URL+"/headers", opts); err != nil {
+		t.Errorf("Probe failed: %v", err)
+	}
+}
+
+func TestProbeInvalidURL(t *testing.T) {
+	if _, err := Probe("http://[::1", DefaultOptions()); err == nil {
+		t.Error("Probe of an invalid URL succeeded")
+	}
+}
+
+// TestProbeTimeoutDuringDial lets the client give up while the dial is
+// still running, so the trace callbacks fire after Probe returned; run with
+// -race to check they do not touch the returned timing
+func TestProbeTimeoutDuringDial(t *testing.T) {
+	server := newServer(t, http.StatusOK, "ok")
+	dialed := make(chan struct{})
+	opts := DefaultOptions()
+	opts.Timeout = 20 * time.Millisecond
+	opts.Dialer = &net.Dialer{Control: func(string, string, syscall.RawConn) error {
+		time.Sleep(100 * time.Millisecond)
+		close(dialed)
+		return nil
+	}}
+
+	timing, err := Probe(server.URL, opts)
+	if err == nil {
+		t.Fatalf("Probe succeeded despite the dial outlasting the timeout")
+	}
+	<-dialed
+	time.Sleep(10 * time.Millisecond) // Let ConnectDone run
+	if timing.Addr != "" || timing.Connect != 0 {
+		t.Errorf("Connect = %v to %q, want no connection", timing.Connect, timing.Addr)
+	}
+}
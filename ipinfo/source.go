@@ -0,0 +1,142 @@
+package ipinfo
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Source selects the interface or local address probes are sent from. The
+// zero Source leaves the choice to the routing table.
+type Source struct {
+	Interface string // Bound with SO_BINDTODEVICE, Linux only
+	Address   string // Local address to send from; takes precedence over Interface
+}
+
+// ParseSource reads a source written as an interface name or an IP address
+func ParseSource(s string) Source {
+	s = strings.TrimSpace(s)
+	if net.ParseIP(s) != nil {
+		return Source{Address: s}
+	}
+	return Source{Interface: s}
+}
+
+// String formats the source in the syntax accepted by ParseSource
+func (s Source) String() string {
+	if s.Address != "" {
+		return s.Address
+	}
+	return s.Interface
+}
+
+// Label names the source for display, e.g. "eth0" or "10.0.0.5 (eth0)"
+func (s Source) Label() string {
+	if s.Address != "" && s.Interface != "" {
+		return fmt.Sprintf("%s (%s)", s.Address, s.Interface)
+	}
+	return s.String()
+}
+
+// IsZero reports whether no source is selected
+func (s Source) IsZero() bool {
+	return s.Address == "" && s.Interface == ""
+}
+
+// Sources lists the interfaces that are up, each followed by its addresses
+func Sources() ([]Source, error) {
+	details, err := GetIpDetails()
+	if err != nil {
+		return nil, err
+	}
+	var sources []Source
+	for _, detail := range details {
+		if detail.Flags&net.FlagUp == 0 {
+			continue
+		}
+		sources = append(sources, Source{Interface: detail.Name})
+		for _, cidr := range detail.IPs {
+			ip, _, err := net.ParseCIDR(cidr)
+			if err != nil {
+				continue
+			}
+			sources = append(sources, Source{Interface: detail.Name, Address: ip.String()})
+		}
+	}
+	return sources, nil
+}
+
+// Check verifies that the interface or address of s exists on this host
+func (s Source) Check() error {
+	if s.IsZero() {
+		return nil
+	}
+	if s.Address != "" {
+		ip := net.ParseIP(s.Address)
+		if ip == nil {
+			return fmt.Errorf("invalid source address %q", s.Address)
+		}
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			return fmt.Errorf("failed to fetch addresses: %w", err)
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+				return nil
+			}
+		}
+		return fmt.Errorf("source address %s is not configured on any interface", s.Address)
+	}
+	if _, err := net.InterfaceByName(s.Interface); err != nil {
+		return fmt.Errorf("unknown source interface %q", s.Interface)
+	}
+	if !canBindToDevice {
+		return fmt.Errorf("sending from interface %s needs Linux; choose one of its addresses instead", s.Interface)
+	}
+	return nil
+}
+
+// Control returns a socket control function binding to s.Interface, or nil
+// when no interface binding is needed. It is meant for net.Dialer and
+// net.ListenConfig.
+func (s Source) Control() func(network, address string, c syscall.RawConn) error {
+	if s.Address != "" || s.Interface == "" {
+		return nil
+	}
+	iface := s.Interface
+	return func(network, address string, c syscall.RawConn) error {
+		return bindToDevice(c, iface)
+	}
+}
+
+// Dialer returns a dialer for network ("tcp" or "udp") connecting from s
+func (s Source) Dialer(network string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout, Control: s.Control()}
+	if ip := net.ParseIP(s.Address); ip != nil {
+		if strings.HasPrefix(network, "udp") {
+			d.LocalAddr = &net.UDPAddr{IP: ip}
+		} else {
+			d.LocalAddr = &net.TCPAddr{IP: ip}
+		}
+	}
+	return d
+}
+
+// ListenConfig returns a listen configuration binding sockets to s.Interface
+func (s Source) ListenConfig() *net.ListenConfig {
+	return &net.ListenConfig{Control: s.Control()}
+}
+
+// ListenAddress returns the local address to listen on for raw sockets,
+// the unspecified address of the family unless s selects an address
+func (s Source) ListenAddress(ipv6 bool) string {
+	if s.Address != "" {
+		return s.Address
+	}
+	if ipv6 {
+		return "::"
+	}
+	return "0.0.0.0"
+}
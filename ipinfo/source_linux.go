@@ -0,0 +1,22 @@
+//go:build linux
+
+package ipinfo
+
+import (
+	"os"
+	"syscall"
+)
+
+const canBindToDevice = true
+
+// bindToDevice sets SO_BINDTODEVICE so that the socket only uses iface
+func bindToDevice(c syscall.RawConn, iface string) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+	})
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", opErr)
+}
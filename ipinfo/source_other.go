@@ -0,0 +1,15 @@
+//go:build !linux
+
+package ipinfo
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const canBindToDevice = false
+
+// bindToDevice is only supported on Linux; elsewhere a source address is used
+func bindToDevice(c syscall.RawConn, iface string) error {
+	return fmt.Errorf("binding to interface %s is only supported on Linux", iface)
+}
@@ -19,6 +19,8 @@ type IPTables struct {
 	app        *tview.Application
 }
 
+// NewIPTables creates an IPTables showing its output in resultView. app and
+// resultView may be nil when only RoutingTable is used.
 func NewIPTables(app *tview.Application, resultView *tview.TextView) *IPTables {
 	return &IPTables{
 		resultView: resultView,
@@ -74,9 +76,19 @@ func NewIPTables(app *tview.Application, resultView *tview.TextView) *IPTables {
 // }
 
 func (ipt *IPTables) ShowRoutingTable() error {
-	ipt.resultView.Clear()
-	ipt.resultView.SetText("Fetching IP routing table...\n")
+	ipt.updateText("Fetching IP routing table...\n")
 
+	text, err := ipt.RoutingTable()
+	if err != nil {
+		ipt.updateText(err.Error())
+		return err
+	}
+	ipt.updateText(text)
+	return nil
+}
+
+// RoutingTable returns the routing table as printed by the system tools
+func (ipt *IPTables) RoutingTable() (string, error) {
 	var output strings.Builder
 	output.WriteString("IP Routing Table:\n")
 	output.WriteString("--------------------------------------------------\n")
@@ -85,22 +97,18 @@ func (ipt *IPTables) ShowRoutingTable() error {
 	if runtime.GOOS == "windows" {
 		if err := ipt.tryExecCommand("route", []string{"print"}, &output); err != nil {
 			// No fallback on Windows; just show error
-			ipt.updateText(fmt.Sprintf("Failed to fetch routing table: %v\nNo fallback available on Windows.", err))
-			return err
+			return "", fmt.Errorf("failed to fetch routing table: %v\nNo fallback available on Windows", err)
 		}
 	} else {
 		// Linux-based systems (including BusyBox, Alpine)
 		if err := ipt.tryExecCommand("ip", []string{"route"}, &output); err != nil {
 			log.Printf("ip route command failed: %v, attempting fallback", err)
-			if err := ipt.parseProcNetRoute(&output); err != nil {
-				ipt.updateText(fmt.Sprintf("Failed to fetch routing table: %v\nFallback (/proc/net/route) also failed: %v", err, err))
-				return err
+			if fallbackErr := ipt.parseProcNetRoute(&output); fallbackErr != nil {
+				return "", fmt.Errorf("failed to fetch routing table: %v\nFallback (/proc/net/route) also failed: %v", err, fallbackErr)
 			}
 		}
 	}
-
-	ipt.updateText(output.String())
-	return nil
+	return output.String(), nil
 }
 
 func (ipt *IPTables) tryExecCommand(cmdName string, args []string, output *strings.Builder) error {
@@ -186,6 +194,9 @@ func hexToIP(hexStr string) (string, error) {
 }
 
 func (ipt *IPTables) updateText(text string) {
+	if ipt.app == nil {
+		return
+	}
 	ipt.app.QueueUpdateDraw(func() {
 		ipt.resultView.SetText(text)
 	})
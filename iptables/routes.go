@@ -0,0 +1,132 @@
+package iptables
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Route is one entry of the routing table
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway,omitempty"`
+	Netmask     string `json:"netmask,omitempty"`
+	Interface   string `json:"interface,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Metric      string `json:"metric,omitempty"`
+}
+
+// Routes reads the routing table in structured form, from route print on
+// Windows and from ip route or /proc/net/route elsewhere
+func Routes() ([]Route, error) {
+	if runtime.GOOS == "windows" {
+		out, err := exec.Command("route", "print", "-4").Output()
+		if err != nil {
+			return nil, fmt.Errorf("route print failed: %v", err)
+		}
+		return parseRoutePrint(out), nil
+	}
+
+	out, err := exec.Command("ip", "route").Output()
+	if err == nil {
+		return parseIPRoute(out), nil
+	}
+	routes, fallbackErr := readProcNetRoute()
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("ip route failed: %v; fallback (/proc/net/route) also failed: %v", err, fallbackErr)
+	}
+	return routes, nil
+}
+
+// parseIPRoute parses lines such as
+// "default via 192.168.1.1 dev eth0 proto dhcp src 192.168.1.10 metric 100"
+func parseIPRoute(out []byte) []Route {
+	var routes []Route
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		route := Route{Destination: fields[0]}
+		for i := 1; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "via":
+				route.Gateway = fields[i+1]
+			case "dev":
+				route.Interface = fields[i+1]
+			case "src":
+				route.Source = fields[i+1]
+			case "metric":
+				route.Metric = fields[i+1]
+			default:
+				continue
+			}
+			i++
+		}
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// parseRoutePrint parses the IPv4 active routes of route print, whose lines
+// are "Network Destination  Netmask  Gateway  Interface  Metric"
+func parseRoutePrint(out []byte) []Route {
+	var routes []Route
+	active := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.Contains(line, "Active Routes:"):
+			active = true
+			continue
+		case strings.Contains(line, "Persistent Routes:"):
+			active = false
+		}
+		fields := strings.Fields(line)
+		if !active || len(fields) != 5 || net.ParseIP(fields[0]) == nil {
+			continue
+		}
+		routes = append(routes, Route{
+			Destination: fields[0],
+			Netmask:     fields[1],
+			Gateway:     fields[2],
+			Interface:   fields[3],
+			Metric:      fields[4],
+		})
+	}
+	return routes
+}
+
+// readProcNetRoute reads the IPv4 routing table from /proc/net/route
+func readProcNetRoute() ([]Route, error) {
+	data, err := os.ReadFile("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 8 {
+			continue
+		}
+		dest, _ := hexToIP(fields[1])
+		gateway, _ := hexToIP(fields[2])
+		mask, _ := hexToIP(fields[7])
+		routes = append(routes, Route{
+			Destination: dest,
+			Gateway:     gateway,
+			Netmask:     mask,
+			Interface:   fields[0],
+			Metric:      fields[6],
+		})
+	}
+	return routes, nil
+}
@@ -7,7 +7,9 @@ import (
 	"runtime"
 )
 
-func GetDefaultLogPath() string {
+// GetDataDir returns the directory holding the log file and other files
+// written by IPmaster, creating it if needed
+func GetDataDir() string {
 	var dir string
 	// Determine the default log directory based on the OS.
 	switch runtime.GOOS {
@@ -41,6 +43,10 @@ func GetDefaultLogPath() string {
 		log.Fatalf("failed to create log directory %s: %v", dir, err)
 	}
 
+	return dir
+}
+
+func GetDefaultLogPath() string {
 	// Return a full file path for your log file.
-	return filepath.Join(dir, "ipmaster.log")
+	return filepath.Join(GetDataDir(), "ipmaster.log")
 }
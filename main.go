@@ -1,14 +1,28 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
+	"github.com/a-tharva/ipmaster/cli"
 	"github.com/a-tharva/ipmaster/logging"
+	"github.com/a-tharva/ipmaster/metrics"
 	"github.com/a-tharva/ipmaster/ui"
 )
 
 func main() {
+	metricsAddr := flag.String("metrics", "", "serve Prometheus metrics of the Ping page on this address, e.g. :9101")
+	headless := flag.Bool("headless", false, "monitor targets without the TUI, serving metrics on -metrics (default :9101)")
+	targets := flag.String("targets", "", "comma-separated targets to monitor in headless mode")
+	group := flag.String("group", "", "saved target group to monitor in headless mode")
+	flag.Usage = func() {
+		cli.Usage(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
 	// ipAddresses := []string{"8.8.8.8", "1.1.1.1", "8.8.4.4"}
 	// ping.StartPing(ipAddresses, "")
@@ -21,6 +35,33 @@ func main() {
 	log.SetOutput(logFile)
 	log.Println("Starting IPmaster...")
 
+	if flag.NArg() > 0 {
+		code := cli.Run(flag.Arg(0), flag.Args()[1:], os.Stdout, os.Stderr)
+		logFile.Close()
+		os.Exit(code)
+	}
+
+	if *headless {
+		if *metricsAddr == "" {
+			*metricsAddr = ":9101"
+		}
+		if err := runHeadless(*targets, *group, *metricsAddr); err != nil {
+			log.Printf("Headless mode failed: %v", err)
+			fmt.Fprintln(os.Stderr, "ipmaster:", err)
+			os.Exit(1)
+		}
+		log.Println("IPmaster terminated.")
+		return
+	}
+
+	if *metricsAddr != "" {
+		exporter := metrics.NewExporter()
+		if _, err := exporter.Serve(*metricsAddr); err != nil {
+			log.Fatalf("Failed to serve metrics: %v", err)
+		}
+		ui.SetMetrics(exporter)
+	}
+
 	if err := ui.Start(); err != nil {
 		log.Fatalf("Failed to start UI: %v", err)
 	}
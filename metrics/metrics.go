@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// Buckets are the upper bounds, in seconds, of the RTT histogram
+var Buckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Labels are the user-facing names attached to a target's series
+type Labels struct {
+	Group string // Saved group the target was loaded from
+	Label string // Label given to the target in its group
+}
+
+// series holds the metrics of one target
+type series struct {
+	spec    ping.Spec
+	labels  Labels
+	buckets []uint64 // Cumulative counts per entry of Buckets
+	sum     float64  // Sum of RTTs in seconds
+	count   uint64   // Number of replies
+	sent    uint64
+	lost    uint64
+	up      bool
+}
+
+// Exporter turns probe results into Prometheus metrics. It is safe for
+// concurrent use.
+type Exporter struct {
+	mu     sync.Mutex
+	labels map[string]Labels
+	series map[string]*series
+}
+
+// NewExporter creates an Exporter without series
+func NewExporter() *Exporter {
+	return &Exporter{labels: make(map[string]Labels), series: make(map[string]*series)}
+}
+
+// SetTargets declares the monitored targets, keyed by spec string, with
+// their labels. Series of targets not listed are dropped.
+func (e *Exporter) SetTargets(targets map[string]Labels) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.labels = targets
+	for target, s := range e.series {
+		labels, ok := targets[target]
+		if !ok {
+			delete(e.series, target)
+			continue
+		}
+		s.labels = labels
+	}
+}
+
+// Observe records a probe result
+func (e *Exporter) Observe(res ping.Result) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	labels, ok := e.labels[res.Target]
+	if !ok {
+		return // Result of a target that is no longer monitored
+	}
+	s, ok := e.series[res.Target]
+	if !ok {
+		s = &series{spec: res.Spec, labels: labels, buckets: make([]uint64, len(Buckets))}
+		e.series[res.Target] = s
+	}
+
+	s.sent++
+	s.up = res.Err == nil
+	if res.Err != nil {
+		s.lost++
+		return
+	}
+	rtt := res.RTT.Seconds()
+	s.sum += rtt
+	s.count++
+	for i, bound := range Buckets {
+		if rtt <= bound {
+			s.buckets[i]++
+		}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	targets := make([]string, 0, len(e.series))
+	for target := range e.series {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+
+	var b strings.Builder
+	b.WriteString("# HELP ipmaster_probe_rtt_seconds Round-trip time of successful probes.\n")
+	b.WriteString("# TYPE ipmaster_probe_rtt_seconds histogram\n")
+	for _, target := range targets {
+		s := e.series[target]
+		for i, bound := range Buckets {
+			fmt.Fprintf(&b, "ipmaster_probe_rtt_seconds_bucket{%s,le=%q} %d\n",
+				s.labelText(target), strconv.FormatFloat(bound, 'g', -1, 64), s.buckets[i])
+		}
+		fmt.Fprintf(&b, "ipmaster_probe_rtt_seconds_bucket{%s,le=\"+Inf\"} %d\n", s.labelText(target), s.count)
+		fmt.Fprintf(&b, "ipmaster_probe_rtt_seconds_sum{%s} %s\n", s.labelText(target), strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "ipmaster_probe_rtt_seconds_count{%s} %d\n", s.labelText(target), s.count)
+	}
+
+	writeSimple := func(name, help, kind string, value func(*series) uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, target := range targets {
+			s := e.series[target]
+			fmt.Fprintf(&b, "%s{%s} %d\n", name, s.labelText(target), value(s))
+		}
+	}
+	writeSimple("ipmaster_probes_sent_total", "Probes sent.", "counter", func(s *series) uint64 { return s.sent })
+	writeSimple("ipmaster_probes_lost_total", "Probes without a valid reply.", "counter", func(s *series) uint64 { return s.lost })
+	writeSimple("ipmaster_target_up", "Whether the last probe succeeded.", "gauge", func(s *series) uint64 {
+		if s.up {
+			return 1
+		}
+		return 0
+	})
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labelText renders the label set of a series, without braces
+func (s *series) labelText(target string) string {
+	return fmt.Sprintf(`target=%s,host=%s,probe=%s,group=%s,label=%s`,
+		quote(target), quote(s.spec.Host), quote(strings.ToLower(s.spec.Label())), quote(s.labels.Group), quote(s.labels.Label))
+}
+
+// quote escapes a label value as required by the exposition format
+func quote(v string) string {
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+	return `"` + v + `"`
+}
+
+// Serve starts serving /metrics on addr in the background and returns the
+// server so it can be shut down. The server's Addr is the address actually
+// listened on, which tells the port when addr asks for any.
+func (e *Exporter) Serve(addr string) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	server := &http.Server{Addr: listener.Addr().String(), Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving metrics on http://%s/metrics", listener.Addr())
+	return server, nil
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// scrape fetches the metrics served on addr as a map from series, the
+// metric name with its labels, to value
+func scrape(t *testing.T, addr string) map[string]string {
+	t.Helper()
+	resp, err := http.Get("http://" + addr + "/metrics")
+	if err != nil {
+		t.Fatalf("scrape failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the text exposition format", ct)
+	}
+
+	series := make(map[string]string)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+		i := strings.LastIndex(line, " ")
+		series[line[:i]] = line[i+1:]
+	}
+	return series
+}
+
+// result builds a probe result of target, lost unless rtt is positive
+func result(t *testing.T, target string, rtt time.Duration) ping.Result {
+	t.Helper()
+	spec, err := ping.ParseSpec(target)
+	if err != nil {
+		t.Fatalf("ParseSpec(%q) failed: %v", target, err)
+	}
+	res := ping.Result{Target: spec.String(), Spec: spec, RTT: rtt}
+	if rtt <= 0 {
+		res.Err = errors.New("timeout")
+	}
+	return res
+}
+
+func TestExporter(t *testing.T) {
+	e := NewExporter()
+	e.SetTargets(map[string]Labels{
+		"8.8.8.8":        {Group: "dns", Label: `Google "primary"`},
+		"1.1.1.1:tcp/53": {Group: "dns", Label: `back\slash` + "\n"},
+	})
+	server, err := e.Serve("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+	defer server.Shutdown(context.Background())
+
+	for _, rtt := range []time.Duration{700 * time.Microsecond, 3 * time.Millisecond, 3 * time.Millisecond, 0} {
+		e.Observe(result(t, "8.8.8.8", rtt))
+	}
+	e.Observe(result(t, "1.1.1.1:tcp/53", 20*time.Millisecond))
+	e.Observe(result(t, "9.9.9.9", time.Millisecond)) // Not a declared target
+
+	google := `target="8.8.8.8",host="8.8.8.8",probe="icmp",group="dns",label="Google \"primary\""`
+	tcp := `target="1.1.1.1:tcp/53",host="1.1.1.1",probe="tcp/53",group="dns",label="back\\slash\n"`
+	got := scrape(t, server.Addr)
+
+	want := map[string]string{
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="0.0005"}`: "0",
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="0.001"}`:  "1",
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="0.0025"}`: "1",
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="0.005"}`:  "3",
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="10"}`:     "3",
+		"ipmaster_probe_rtt_seconds_bucket{" + google + `,le="+Inf"}`:   "3",
+		"ipmaster_probe_rtt_seconds_count{" + google + `}`:              "3",
+		"ipmaster_probes_sent_total{" + google + `}`:                    "4",
+		"ipmaster_probes_lost_total{" + google + `}`:                    "1",
+		"ipmaster_target_up{" + google + `}`:                            "0",
+		"ipmaster_probe_rtt_seconds_bucket{" + tcp + `,le="0.01"}`:      "0",
+		"ipmaster_probe_rtt_seconds_bucket{" + tcp + `,le="0.025"}`:     "1",
+		"ipmaster_probe_rtt_seconds_bucket{" + tcp + `,le="+Inf"}`:      "1",
+		"ipmaster_probes_sent_total{" + tcp + `}`:                       "1",
+		"ipmaster_probes_lost_total{" + tcp + `}`:                       "0",
+		"ipmaster_target_up{" + tcp + `}`:                               "1",
+	}
+	for series, value := range want {
+		if got[series] != value {
+			t.Errorf("%s = %q, want %s", series, got[series], value)
+		}
+	}
+	sum, err := strconv.ParseFloat(got["ipmaster_probe_rtt_seconds_sum{"+google+"}"], 64)
+	if err != nil || sum < 0.00669 || sum > 0.00671 {
+		t.Errorf("RTT sum = %v (%v), want 0.0067", sum, err)
+	}
+	// Each series has one line per bucket plus +Inf, sum, count, sent, lost and up
+	if want := 2 * (len(Buckets) + 6); len(got) != want {
+		t.Errorf("scraped %d series, want %d", len(got), want)
+	}
+	for series := range got {
+		if strings.Contains(series, "9.9.9.9") {
+			t.Errorf("undeclared target exported: %s", series)
+		}
+	}
+
+	// Dropping a target removes its series; relabeling applies to the rest
+	e.SetTargets(map[string]Labels{"1.1.1.1:tcp/53": {Group: "resolvers"}})
+	got = scrape(t, server.Addr)
+	if want := len(Buckets) + 6; len(got) != want {
+		t.Errorf("scraped %d series after SetTargets, want %d", len(got), want)
+	}
+	relabeled := `ipmaster_probes_sent_total{target="1.1.1.1:tcp/53",host="1.1.1.1",probe="tcp/53",group="resolvers",label=""}`
+	if got[relabeled] != "1" {
+		t.Errorf("%s = %q, want 1", relabeled, got[relabeled])
+	}
+	e.Observe(result(t, "8.8.8.8", time.Millisecond))
+	for series := range scrape(t, server.Addr) {
+		if strings.Contains(series, "8.8.8.8") {
+			t.Errorf("dropped target exported again: %s", series)
+		}
+	}
+}
+
+func TestExporterWriteToEmpty(t *testing.T) {
+	var b strings.Builder
+	if _, err := NewExporter().WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo failed: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		if !strings.HasPrefix(line, "# ") {
+			t.Errorf("exporter without series wrote %q", line)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package ping
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/a-tharva/ipmaster/ipinfo"
+)
+
+// ParseDSCP reads a differentiated services codepoint written as a class
+// name (be, ef, va, cs0-cs7, af11-af43) or a number from 0 to 63
+func ParseDSCP(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "be", "df":
+		return 0, nil
+	case "ef":
+		return 46, nil
+	case "va":
+		return 44, nil
+	}
+	if rest, ok := strings.CutPrefix(s, "cs"); ok {
+		if n, err := strconv.Atoi(rest); err == nil && n >= 0 && n <= 7 && len(rest) == 1 {
+			return n * 8, nil
+		}
+	}
+	if rest, ok := strings.CutPrefix(s, "af"); ok && len(rest) == 2 {
+		class, drop := int(rest[0]-'0'), int(rest[1]-'0')
+		if class >= 1 && class <= 4 && drop >= 1 && drop <= 3 {
+			return class*8 + drop*2, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 && n <= 63 {
+		return n, nil
+	}
+	return 0, fmt.Errorf("invalid DSCP %q, expected a class such as ef, af41 or cs1, or 0-63", s)
+}
+
+// DSCPName returns the class name of a codepoint, or its number when it
+// has none
+func DSCPName(dscp int) string {
+	class, drop := dscp/8, dscp%8
+	switch {
+	case dscp == 0:
+		return "be"
+	case dscp == 46:
+		return "ef"
+	case dscp == 44:
+		return "va"
+	case drop == 0:
+		return fmt.Sprintf("cs%d", class)
+	case class >= 1 && class <= 4 && drop%2 == 0 && drop <= 6:
+		return fmt.Sprintf("af%d%d", class, drop/2)
+	}
+	return strconv.Itoa(dscp)
+}
+
+// dialer returns a dialer for network sending from s.Source and marking
+// packets with s.DSCP
+func dialer(network string, s Settings) *net.Dialer {
+	d := ipinfo.ParseSource(s.Source).Dialer(network, s.Timeout)
+	if s.DSCP == 0 {
+		return d
+	}
+	bind, tos := d.Control, s.DSCP<<2
+	d.Control = func(network, address string, c syscall.RawConn) error {
+		if bind != nil {
+			if err := bind(network, address, c); err != nil {
+				return err
+			}
+		}
+		return setTOS(c, strings.HasSuffix(network, "6"), tos)
+	}
+	return d
+}
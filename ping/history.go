@@ -0,0 +1,52 @@
+package ping
+
+import "time"
+
+// Sample is one point in a target's latency history
+type Sample struct {
+	Time time.Time
+	RTT  time.Duration
+	Lost bool
+}
+
+// History is a fixed-size ring buffer of samples, oldest overwritten first
+type History struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+// NewHistory creates a History holding up to size samples
+func NewHistory(size int) *History {
+	if size < 1 {
+		size = 1
+	}
+	return &History{samples: make([]Sample, size)}
+}
+
+// Add appends a sample, evicting the oldest once the buffer is full
+func (h *History) Add(s Sample) {
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Since returns the samples taken at or after t, oldest first
+func (h *History) Since(t time.Time) []Sample {
+	var out []Sample
+	if h.full {
+		out = appendSince(out, h.samples[h.next:], t)
+	}
+	return appendSince(out, h.samples[:h.next], t)
+}
+
+func appendSince(out, samples []Sample, t time.Time) []Sample {
+	for _, s := range samples {
+		if !s.Time.Before(t) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
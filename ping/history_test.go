@@ -0,0 +1,44 @@
+package ping
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+
+	tests := []struct {
+		name  string
+		size  int
+		add   int // Samples added, one per second from start
+		since int // Seconds after start to query from
+		want  []int
+	}{
+		{name: "empty", size: 3, add: 0, since: 0, want: nil},
+		{name: "partly filled", size: 3, add: 2, since: 0, want: []int{0, 1}},
+		{name: "exactly full", size: 3, add: 3, since: 0, want: []int{0, 1, 2}},
+		{name: "wrapped keeps newest", size: 3, add: 5, since: 0, want: []int{2, 3, 4}},
+		{name: "since filters older", size: 3, add: 5, since: 3, want: []int{3, 4}},
+		{name: "since after last", size: 3, add: 5, since: 9, want: nil},
+		{name: "size below one", size: 0, add: 2, since: 0, want: []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(tt.size)
+			for i := range tt.add {
+				h.Add(Sample{Time: at(i), RTT: time.Duration(i) * time.Millisecond})
+			}
+			got := h.Since(at(tt.since))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d samples, want %d", len(got), len(tt.want))
+			}
+			for i, s := range got {
+				if !s.Time.Equal(at(tt.want[i])) || s.RTT != time.Duration(tt.want[i])*time.Millisecond {
+					t.Errorf("sample %d = %v %v, want the one from second %d", i, s.Time, s.RTT, tt.want[i])
+				}
+			}
+		})
+	}
+}
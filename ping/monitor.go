@@ -0,0 +1,433 @@
+package ping
+
+import (
+	"log"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a-tharva/ipmaster/httpprobe"
+	"github.com/a-tharva/ipmaster/resolve"
+)
+
+// Result is the outcome of a single probe against a target
+type Result struct {
+	Target      string // Spec.String(), the key used by Monitor queries
+	Spec        Spec
+	Addr        string        // Address the target resolved to, empty if resolution failed
+	ResolveTime time.Duration // Duration of the lookup that produced Addr, zero for IP literals
+	Seq         int
+	RTT         time.Duration
+	HTTP        *httpprobe.Timing // Timing breakdown of HTTP probes
+	Anomalies   Anomalies         // Duplicate, reordered and late replies of ICMP probes
+	Err         error
+	Time        time.Time
+}
+
+// DefaultWindow is the number of recent results windowed statistics cover
+const DefaultWindow = 20
+
+// schedulerTick is how often the monitor checks which targets are due
+const schedulerTick = 250 * time.Millisecond
+
+// Target is a monitored host with the settings its probes use. Results
+// and per-target queries are keyed by Spec.String().
+type Target struct {
+	Spec     Spec
+	Settings Settings
+}
+
+// Monitor periodically probes a set of targets and delivers every Result to
+// its subscribers. Each target is probed on its own interval by a bounded
+// pool of workers. Each Monitor owns its own state, so several can run at once.
+type Monitor struct {
+	Window      int  // Number of recent results kept for windowed statistics
+	HistorySize int  // Number of samples kept per target for latency graphs
+	OutageSize  int  // Number of outages kept per target for the timeline
+	Workers     int  // Maximum number of probes in flight at once
+	Mode        Mode // ICMP socket mode used for probes
+
+	mu        sync.Mutex
+	family    resolve.Family
+	reResolve bool
+	targets   []string
+	state     map[string]*targetState
+	handlers  []func(Result)
+	late      []func(target string, seq int, a Anomalies)
+	kick      chan struct{}
+	stop      chan struct{}
+}
+
+// targetState is everything the monitor tracks for one target
+type targetState struct {
+	spec     Spec
+	settings Settings
+	last     time.Time // When the last probe was started
+	inFlight bool      // A probe has been dispatched and not finished yet
+	seq      int
+	answered int // Seq of the latest probe that got a reply
+	addr     *resolve.Result
+	stats    *Stats
+	history  *History
+	outages  *Outages
+}
+
+// NewMonitor creates a Monitor with no targets
+func NewMonitor() *Monitor {
+	return &Monitor{
+		Window:      DefaultWindow,
+		HistorySize: 3600,
+		OutageSize:  100,
+		Workers:     16,
+		Mode:        DetectMode(),
+		family:      resolve.Any,
+		state:       make(map[string]*targetState),
+		kick:        make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers fn to be called with every probe result. fn is called
+// from the monitor's goroutines and must not block for long.
+func (m *Monitor) Subscribe(fn func(Result)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, fn)
+}
+
+// SubscribeLate registers fn to be called with anomalies of probe seq of
+// target noticed after its result was delivered, such as late replies. They
+// are already included in the target's statistics. fn is called from the
+// monitor's goroutines and must not block for long.
+func (m *Monitor) SubscribeLate(fn func(target string, seq int, a Anomalies)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.late = append(m.late, fn)
+}
+
+// SetTargets replaces the monitored targets and probes them immediately.
+// Targets that were already monitored keep their statistics and history;
+// a target listed more than once is monitored once.
+func (m *Monitor) SetTargets(targets []Target) {
+	m.mu.Lock()
+	m.targets = nil
+	state := make(map[string]*targetState, len(targets))
+	for _, target := range targets {
+		key := target.Spec.String()
+		if _, dup := state[key]; dup {
+			continue
+		}
+		st, ok := m.state[key]
+		if !ok {
+			st = &targetState{
+				stats:   NewStats(m.Window),
+				history: NewHistory(m.HistorySize),
+				outages: NewOutages(m.OutageSize),
+			}
+		}
+		st.spec = target.Spec
+		st.settings = target.Settings
+		st.last = time.Time{}
+		state[key] = st
+		m.targets = append(m.targets, key)
+	}
+	m.state = state
+	m.mu.Unlock()
+
+	select {
+	case m.kick <- struct{}{}:
+	default:
+	}
+}
+
+// SetResolution selects the address family used for hostnames and whether
+// they are resolved again before every probe so DNS changes show up
+func (m *Monitor) SetResolution(family resolve.Family, reResolve bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.family = family
+	m.reResolve = reResolve
+	for _, st := range m.state {
+		st.addr = nil
+	}
+}
+
+// UpdateSettings changes the probe settings of a monitored target
+func (m *Monitor) UpdateSettings(target string, settings Settings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.state[target]; ok {
+		st.settings = settings
+	}
+}
+
+// Settings returns the probe settings of target
+func (m *Monitor) Settings(target string) Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.state[target]; ok {
+		return st.settings
+	}
+	return DefaultSettings()
+}
+
+// Targets returns the hosts being monitored
+func (m *Monitor) Targets() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.targets...)
+}
+
+// Statistics returns the session and sliding-window statistics for target
+func (m *Monitor) Statistics(target string) (session, window Statistics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.state[target]
+	if !ok {
+		return Statistics{}, Statistics{}
+	}
+	return st.stats.Session(), st.stats.Window()
+}
+
+// History returns the latency samples of target taken at or after since
+func (m *Monitor) History(target string, since time.Time) []Sample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.state[target]
+	if !ok {
+		return nil
+	}
+	return st.history.Since(since)
+}
+
+// Outages returns the recent outages of target, oldest first, and the
+// summary of all its outages
+func (m *Monitor) Outages(target string) ([]Outage, OutageSummary) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.state[target]
+	if !ok {
+		return nil, OutageSummary{}
+	}
+	return st.outages.List(), st.outages.Summary(time.Now())
+}
+
+// Start begins probing in the background until Stop is called
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	if m.stop != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.stop = stop
+	m.mu.Unlock()
+
+	go m.run(stop)
+}
+
+// Stop halts probing; results of probes already in flight are discarded
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+	log.Println("Stopped ping monitor")
+}
+
+// probeJob asks a worker to probe one target
+type probeJob struct {
+	target string
+	st     *targetState
+}
+
+func (m *Monitor) run(stop chan struct{}) {
+	jobs := make(chan probeJob)
+	for i := 0; i < max(m.Workers, 1); i++ {
+		go m.worker(stop, jobs)
+	}
+
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.kick:
+			m.dispatchDue(stop, jobs)
+		case <-ticker.C:
+			m.dispatchDue(stop, jobs)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// worker probes targets handed over by dispatchDue until stop is closed
+func (m *Monitor) worker(stop chan struct{}, jobs <-chan probeJob) {
+	for {
+		select {
+		case job := <-jobs:
+			m.probe(stop, job.target, job.st)
+			m.mu.Lock()
+			job.st.inFlight = false
+			m.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// dispatchDue hands every target whose interval has elapsed to an idle
+// worker. Targets whose previous probe is still outstanding skip this round
+// rather than queueing, and targets left over when every worker is busy are
+// retried on the next tick.
+func (m *Monitor) dispatchDue(stop chan struct{}, jobs chan<- probeJob) {
+	now := time.Now()
+	var due []probeJob
+	m.mu.Lock()
+	for _, target := range m.targets {
+		st := m.state[target]
+		if st.inFlight || (!st.last.IsZero() && now.Sub(st.last) < st.settings.Interval) {
+			continue
+		}
+		due = append(due, probeJob{target: target, st: st})
+	}
+	// Longest waiting first so a busy pool does not starve later targets
+	sort.SliceStable(due, func(i, j int) bool { return due[i].st.last.Before(due[j].st.last) })
+	m.mu.Unlock()
+
+	for _, job := range due {
+		m.mu.Lock()
+		job.st.inFlight = true
+		job.st.last = now
+		m.mu.Unlock()
+
+		select {
+		case jobs <- job:
+		case <-stop:
+			return
+		default:
+			m.mu.Lock()
+			job.st.inFlight = false
+			job.st.last = time.Time{}
+			m.mu.Unlock()
+			return // Every worker is busy
+		}
+	}
+}
+
+func (m *Monitor) probe(stop chan struct{}, target string, st *targetState) {
+	m.mu.Lock()
+	seq := st.seq
+	st.seq++
+	spec, settings := st.spec, st.settings
+	m.mu.Unlock()
+
+	res := Result{Target: target, Spec: spec, Seq: seq}
+	var err error
+	if spec.Type == HTTP {
+		var timing httpprobe.Timing
+		timing, err = ProbeHTTP(spec, settings)
+		res.HTTP = &timing
+		res.ResolveTime = timing.DNS
+		if host, _, splitErr := net.SplitHostPort(timing.Addr); splitErr == nil {
+			res.Addr = host
+		}
+		if err == nil {
+			res.RTT = timing.Total
+		}
+	} else if addr, resolveErr := m.resolve(st, spec.Host); resolveErr != nil {
+		err = resolveErr
+	} else {
+		res.Addr = addr.Addr.String()
+		res.ResolveTime = addr.Duration
+		res.RTT, res.Anomalies, err = ProbeSpec(spec, res.Addr, settings, m.Mode.Privileged, func(a Anomalies) {
+			m.addLate(stop, target, st, seq, a)
+		})
+	}
+	res.Err = err
+	res.Time = time.Now()
+
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	m.mu.Lock()
+	// SetTargets may have dropped the target while it was being probed
+	if m.state[target] != st {
+		m.mu.Unlock()
+		return
+	}
+	if res.Err == nil {
+		st.answered = max(st.answered, seq)
+	}
+	st.stats.Add(res)
+	st.history.Add(Sample{Time: res.Time, RTT: res.RTT, Lost: res.Err != nil})
+	st.outages.Add(res)
+	m.mu.Unlock()
+
+	m.emit(res)
+}
+
+// addLate records anomalies of probe seq of st noticed after its result. A
+// late reply overtaken by the reply to a later probe also counts as reordered.
+func (m *Monitor) addLate(stop chan struct{}, target string, st *targetState, seq int, a Anomalies) {
+	select {
+	case <-stop:
+		return
+	default:
+	}
+
+	m.mu.Lock()
+	if m.state[target] != st {
+		m.mu.Unlock()
+		return
+	}
+	if st.answered > seq {
+		a.Reordered += a.Late
+	}
+	st.stats.AddAnomalies(a)
+	handlers := append([]func(string, int, Anomalies){}, m.late...)
+	m.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(target, seq, a)
+	}
+}
+
+// resolve returns the address to probe for target, using the cached
+// resolution unless re-resolution is enabled
+func (m *Monitor) resolve(st *targetState, host string) (resolve.Result, error) {
+	m.mu.Lock()
+	cached := st.addr
+	family, reResolve := m.family, m.reResolve
+	m.mu.Unlock()
+	if cached != nil && !reResolve {
+		return *cached, nil
+	}
+
+	res, err := resolve.Lookup(host, family)
+	if err != nil {
+		log.Printf("Resolution failed for %s: %v", host, err)
+		return res, err
+	}
+
+	m.mu.Lock()
+	st.addr = &res
+	m.mu.Unlock()
+	return res, nil
+}
+
+func (m *Monitor) emit(res Result) {
+	m.mu.Lock()
+	handlers := append([]func(Result){}, m.handlers...)
+	m.mu.Unlock()
+
+	for _, fn := range handlers {
+		fn(res)
+	}
+}
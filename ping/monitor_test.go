@@ -0,0 +1,125 @@
+package ping
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestMonitorSetTargetsDedupes(t *testing.T) {
+	m := NewMonitor()
+	var targets []Target
+	for _, text := range ParseIPs("8.8.8.8, 1.1.1.1, 8.8.8.8, 1.1.1.1:tcp/443, 1.1.1.1") {
+		spec, err := ParseSpec(text)
+		if err != nil {
+			t.Fatalf("ParseSpec(%q) failed: %v", text, err)
+		}
+		targets = append(targets, Target{Spec: spec, Settings: DefaultSettings()})
+	}
+	m.SetTargets(targets)
+
+	want := []string{"8.8.8.8", "1.1.1.1", "1.1.1.1:tcp/443"}
+	if got := m.Targets(); !slices.Equal(got, want) {
+		t.Errorf("Targets() = %v, want %v", got, want)
+	}
+	if len(m.state) != len(want) {
+		t.Errorf("monitor tracks %d targets, want %d", len(m.state), len(want))
+	}
+}
+
+func TestMonitorSetTargetsKeepsState(t *testing.T) {
+	m := NewMonitor()
+	spec, _ := ParseSpec("8.8.8.8")
+	m.SetTargets([]Target{{Spec: spec, Settings: DefaultSettings()}})
+	m.state["8.8.8.8"].stats.Add(Result{RTT: 1})
+
+	other, _ := ParseSpec("1.1.1.1")
+	m.SetTargets([]Target{{Spec: other}, {Spec: spec}, {Spec: spec}})
+	if session, _ := m.Statistics("8.8.8.8"); session.Sent != 1 {
+		t.Errorf("8.8.8.8 has %d results after SetTargets, want 1", session.Sent)
+	}
+}
+
+func TestMonitorAddLate(t *testing.T) {
+	m := NewMonitor()
+	spec, _ := ParseSpec("8.8.8.8")
+	m.SetTargets([]Target{{Spec: spec, Settings: DefaultSettings()}})
+	st := m.state["8.8.8.8"]
+	stop := make(chan struct{})
+	var delivered []Anomalies
+	m.SubscribeLate(func(target string, seq int, a Anomalies) {
+		if target != "8.8.8.8" {
+			t.Errorf("late anomalies delivered for %s", target)
+		}
+		delivered = append(delivered, a)
+	})
+
+	// Probe 0 timed out, its reply arrives before probe 1 is answered
+	st.stats.Add(Result{Seq: 0, Err: ErrTimeout})
+	m.addLate(stop, "8.8.8.8", st, 0, Anomalies{Late: 1})
+
+	// Probe 2 timed out, probe 3 is answered before the reply to 2 arrives
+	st.stats.Add(Result{Seq: 1, RTT: ms(1)})
+	st.answered = 1
+	st.stats.Add(Result{Seq: 2, Err: ErrTimeout})
+	st.stats.Add(Result{Seq: 3, RTT: ms(1)})
+	st.answered = 3
+	m.addLate(stop, "8.8.8.8", st, 2, Anomalies{Late: 1, Duplicates: 1})
+
+	session, _ := m.Statistics("8.8.8.8")
+	if want := (Anomalies{Duplicates: 1, Reordered: 1, Late: 2}); session.Anomalies != want {
+		t.Errorf("session anomalies = %+v, want %+v", session.Anomalies, want)
+	}
+	if want := []Anomalies{{Late: 1}, {Duplicates: 1, Reordered: 1, Late: 1}}; !slices.Equal(delivered, want) {
+		t.Errorf("delivered %+v, want %+v", delivered, want)
+	}
+
+	close(stop)
+	m.addLate(stop, "8.8.8.8", st, 3, Anomalies{Duplicates: 1})
+	if session, _ := m.Statistics("8.8.8.8"); session.Anomalies.Duplicates != 1 {
+		t.Errorf("anomalies recorded after Stop")
+	}
+	if len(delivered) != 2 {
+		t.Errorf("anomalies delivered after Stop")
+	}
+}
+
+// TestMonitorProbeRemovedTarget removes a target while its probe is in
+// flight and checks that the result is neither recorded nor delivered
+func TestMonitorProbeRemovedTarget(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	defer server.Close()
+
+	spec, err := ParseSpec(server.URL)
+	if err != nil {
+		t.Fatalf("ParseSpec(%q) failed: %v", server.URL, err)
+	}
+	m := NewMonitor()
+	m.SetTargets([]Target{{Spec: spec, Settings: DefaultSettings()}})
+	var emitted []Result
+	m.Subscribe(func(res Result) { emitted = append(emitted, res) })
+
+	key := spec.String()
+	st := m.state[key]
+	done := make(chan struct{})
+	go func() {
+		m.probe(make(chan struct{}), key, st)
+		close(done)
+	}()
+	<-started
+	m.SetTargets(nil)
+	close(release)
+	<-done
+
+	if len(emitted) != 0 {
+		t.Errorf("removed target emitted %d results", len(emitted))
+	}
+	if session := st.stats.Session(); session.Sent != 0 {
+		t.Errorf("removed target recorded %d results", session.Sent)
+	}
+}
@@ -0,0 +1,108 @@
+package ping
+
+import (
+	"fmt"
+	"time"
+)
+
+// Outage is a run of consecutive lost probes
+type Outage struct {
+	Start time.Time // When the first lost probe completed
+	End   time.Time // When the next reply arrived, zero while the outage lasts
+	Lost  int       // Probes lost during the outage
+}
+
+// Ongoing reports whether no reply has ended the outage yet
+func (o Outage) Ongoing() bool {
+	return o.End.IsZero()
+}
+
+// Duration returns how long the outage lasted, or has lasted until now
+func (o Outage) Duration(now time.Time) time.Duration {
+	if o.Ongoing() {
+		return now.Sub(o.Start)
+	}
+	return o.End.Sub(o.Start)
+}
+
+// OutageSummary counts the outages of a target
+type OutageSummary struct {
+	Count   int
+	Longest time.Duration
+	Lost    int // Probes lost in all outages
+}
+
+// String formats the summary as "3 outages, longest 42s"
+func (s OutageSummary) String() string {
+	switch s.Count {
+	case 0:
+		return "no outages"
+	case 1:
+		return fmt.Sprintf("1 outage, %s", s.Longest.Round(time.Second))
+	}
+	return fmt.Sprintf("%d outages, longest %s", s.Count, s.Longest.Round(time.Second))
+}
+
+// Outages records the loss bursts of one target. The most recent outages
+// are kept in a ring buffer while the summary covers all of them.
+type Outages struct {
+	recent  []Outage
+	next    int
+	full    bool
+	current *Outage
+	summary OutageSummary
+}
+
+// NewOutages creates an Outages keeping the last size outages
+func NewOutages(size int) *Outages {
+	if size < 1 {
+		size = 1
+	}
+	return &Outages{recent: make([]Outage, size)}
+}
+
+// Add records a probe result
+func (o *Outages) Add(res Result) {
+	if res.Err != nil {
+		if o.current == nil {
+			o.current = &Outage{Start: res.Time}
+			o.summary.Count++
+		}
+		o.current.Lost++
+		o.summary.Lost++
+		return
+	}
+	if o.current == nil {
+		return
+	}
+	o.current.End = res.Time
+	o.summary.Longest = max(o.summary.Longest, o.current.Duration(res.Time))
+	o.recent[o.next] = *o.current
+	o.next = (o.next + 1) % len(o.recent)
+	if o.next == 0 {
+		o.full = true
+	}
+	o.current = nil
+}
+
+// List returns the recent outages oldest first, including an ongoing one
+func (o *Outages) List() []Outage {
+	var out []Outage
+	if o.full {
+		out = append(out, o.recent[o.next:]...)
+	}
+	out = append(out, o.recent[:o.next]...)
+	if o.current != nil {
+		out = append(out, *o.current)
+	}
+	return out
+}
+
+// Summary returns the outage counts, with an ongoing outage measured until now
+func (o *Outages) Summary(now time.Time) OutageSummary {
+	s := o.summary
+	if o.current != nil {
+		s.Longest = max(s.Longest, o.current.Duration(now))
+	}
+	return s
+}
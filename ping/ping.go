@@ -1,25 +1,20 @@
 package ping
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"time"
 
-	"github.com/gdamore/tcell/v2"
+	"github.com/a-tharva/ipmaster/ipinfo"
 	probing "github.com/prometheus-community/pro-bing"
-	"github.com/rivo/tview"
 )
 
-var (
-	ipAddresses []string
-	activeIPs   = make(map[string]int)
-	mu          sync.Mutex
-)
-
-func InatailizePing(app *tview.Application) {
-
-}
+// ErrTimeout is reported when a probe receives no reply within its timeout.
+var ErrTimeout = errors.New("request timed out")
 
 func ParseIPs(input string) []string {
 	var ips []string
@@ -29,194 +24,216 @@ func ParseIPs(input string) []string {
 	return ips
 }
 
-// func StartPing(ipAddresses []string, monitoringType string) {
-// 	var wg sync.WaitGroup
-
-// 	for _, ipAddress := range ipAddresses {
-// 		wg.Add(1)
-// 		go func(ip string) {
-// 			defer wg.Done()
-// 			continuousPingIP(ip)
-// 		}(ipAddress)
-// 	}
-
-// 	wg.Wait()
-// }
-
-// func pingIP(ipAddress string) {
-// 	pinger, err := probing.NewPinger(ipAddress)
-// 	if err != nil {
-// 		log.Printf("Error creating pinger for %s: %v\n", ipAddress, err)
-// 		return
-// 	}
-
-// 	pinger.SetPrivileged(true)
-// 	pinger.Count = 3
-
-// 	fmt.Printf("Pinging %s...\n", ipAddress)
-// 	err = pinger.Run()
-// 	if err != nil {
-// 		log.Printf("Error running pinger for %s: %v\n", ipAddress, err)
-// 		return
-// 	}
-
-// 	stats := pinger.Statistics()
-// 	fmt.Printf("\n--- %s ping statistics ---\n", stats.Addr)
-// 	fmt.Printf("%d packets transmitted, %d packets received, %v%% packet loss\n",
-// 		stats.PacketsSent, stats.PacketsRecv, stats.PacketLoss)
-// 	fmt.Printf("round-trip min/avg/max/stddev = %v/%v/%v/%v\n",
-// 		stats.MinRtt, stats.AvgRtt, stats.MaxRtt, stats.StdDevRtt)
-// }
-
-// func continuousPingIP(ipAddress string) {
-// 	pinger, err := probing.NewPinger(ipAddress)
-// 	if err != nil {
-// 		log.Printf("Error creating pinger for %s: %v\n", ipAddress, err)
-// 		return
-// 	}
-
-// 	pinger.SetPrivileged(true)
-// 	pinger.Count = 1
-
-// 	for {
-// 		// Run the pinger
-// 		err = pinger.Run()
-// 		if err != nil {
-// 			log.Printf("Error running pinger for %s: %v\n", ipAddress, err)
-// 			return
-// 		}
-
-// 		// Get statistics
-// 		stats := pinger.Statistics()
-// 		responseTime := stats.AvgRtt.Seconds() * 1000 // Convert to milliseconds
-
-// 		// updateGraph(ipAddress, responseTime)
-
-// 		// Wait for 1 second before the next ping
-// 		time.Sleep(1 * time.Second)
-// 	}
-// }
-
-func pingResult(ip string) float64 {
-	pinger, err := probing.NewPinger(ip)
-	if err != nil {
-		log.Printf("Error creating pinger for %s: %v\n", ip, err)
-		return -1
-	}
+// burstInterval separates echo requests when a probe sends more than one
+const burstInterval = 100 * time.Millisecond
 
-	pinger.SetPrivileged(true)
-	pinger.Count = 1
+// LateWindow is how long echo requests keep listening past the timeout
+// when the caller wants late replies reported
+const LateWindow = time.Second
 
-	err = pinger.Run()
-	if err != nil {
-		log.Printf("Error running pinger for %s: %v\n", ip, err)
-		return -1
-	}
+// Anomalies counts unusual replies to ICMP echo probes
+type Anomalies struct {
+	Duplicates int // Further replies to a request already answered, e.g. from a bridging loop
+	Reordered  int // Replies arriving after the reply to a later request, of the same probe or a later one
+	Late       int // Replies arriving after the timeout; the request still counts as lost
+}
 
-	stats := pinger.Statistics()
-	return stats.AvgRtt.Seconds() * 1000
+// Add returns the sum of a and b
+func (a Anomalies) Add(b Anomalies) Anomalies {
+	return Anomalies{a.Duplicates + b.Duplicates, a.Reordered + b.Reordered, a.Late + b.Late}
 }
 
-func ipResponseStatus(responseTime float64) (string, tcell.Color) {
-	if responseTime < 0 {
-		return "[grey]✖ ", tcell.ColorGrey // Indicate failure
+// Any reports whether any anomaly was counted
+func (a Anomalies) Any() bool {
+	return a != Anomalies{}
+}
+
+// String lists the counted anomalies, e.g. "2 dup, 1 late"
+func (a Anomalies) String() string {
+	var parts []string
+	if a.Duplicates > 0 {
+		parts = append(parts, fmt.Sprintf("%d dup", a.Duplicates))
+	}
+	if a.Reordered > 0 {
+		parts = append(parts, fmt.Sprintf("%d reordered", a.Reordered))
 	}
-	if responseTime < 100 {
-		return "[green]▲ ", tcell.ColorGreen
-	} else if responseTime < 300 {
-		return "[yellow]▲ ", tcell.ColorYellow
+	if a.Late > 0 {
+		parts = append(parts, fmt.Sprintf("%d late", a.Late))
 	}
-	return "[red]▼ ", tcell.ColorRed
+	return strings.Join(parts, ", ")
 }
 
-func PingIP(app *tview.Application, ip string, rowId int, resultTable *tview.Table) {
-	mu.Lock()
-	activeIPs[ip] = rowId
-	mu.Unlock()
-	responseTime := pingResult(ip)
-
-	// status := " "
-	// color := tcell.ColorWhite
-
-	// // Determine the status based on response time
-	// if responseTime < 100 {
-	// 	status = "[green]▲ " // Good response
-	// 	color = tcell.ColorGreen
-	// } else if responseTime < 300 {
-	// 	status = "[yellow]▲ " // Moderate response
-	// 	color = tcell.ColorYellow
-	// } else {
-	// 	status = "[red]▼ " // Bad response
-	// 	color = tcell.ColorRed
-	// }
-
-	status, color := ipResponseStatus(responseTime)
-
-	// Combine status and response time into a single string
-	resStatus := fmt.Sprintf("%s: %.2f ms", ip, responseTime)
-	if responseTime < 0 {
-		resStatus = fmt.Sprintf("%s: Failed", ip)
-	}
+// Settings are the parameters of the probes sent to one target
+type Settings struct {
+	Interval time.Duration // Time between probes
+	Timeout  time.Duration // Time to wait for a reply
+	Size     int           // Echo payload size in bytes
+	TTL      int
+	Count    int           // Echo requests per probe; the probe RTT is their average
+	Warn     time.Duration // RTT from which a reply counts as slow
+	Crit     time.Duration // RTT from which a reply counts as bad
+	Payload  string        // UDP probe payload, "hex:" prefix for binary data
+	Expect   string        // Data a UDP reply or HTTP body must contain, unchecked if empty
+	Source   string        // Interface or local address to send from, see ipinfo.ParseSource
+	DSCP     int           // Differentiated services codepoint marked on probes, see ParseDSCP
+
+	Headers      map[string]string // Extra HTTP request headers
+	ExpectStatus int               // Required HTTP status, any below 400 if zero
+}
 
-	log.Printf("Initial ping for %s: %s", ip, resStatus)
+// DefaultSettings returns the settings used when nothing is configured
+func DefaultSettings() Settings {
+	return Settings{
+		Interval: 2 * time.Second,
+		Timeout:  2 * time.Second,
+		Size:     24,
+		TTL:      64,
+		Count:    1,
+		Warn:     100 * time.Millisecond,
+		Crit:     300 * time.Millisecond,
+	}
+}
 
-	app.QueueUpdateDraw(func() {
-		resultTable.SetCell(rowId, 0, tview.NewTableCell(ip).SetTextColor(tview.Styles.PrimaryTextColor).SetAlign(tview.AlignCenter))
-		resultTable.SetCell(rowId, 1, tview.NewTableCell(status+resStatus).SetTextColor(color).SetAlign(tview.AlignCenter)) // Combine status with resStatus
-	})
-	app.Draw()
+// Probe sends s.Count ICMP echoes to ip and returns the average round-trip
+// time. privileged selects raw sockets over datagram ICMP, see DetectMode.
+func Probe(ip string, s Settings, privileged bool) (time.Duration, error) {
+	rtt, _, err := ProbeEcho(ip, s, privileged, nil)
+	return rtt, err
 }
 
-func RefreshPings(app *tview.Application, resultTable *tview.Table) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	log.Println("Refreshing pings for active IPs:", activeIPs)
-	// if resultTable.GetRowCount() <= 1 {
-	// 	return
-	// }
-	// for row := 1; row < resultTable.GetRowCount(); row++ {
-	// 	ipCell := resultTable.GetCell(row, 0)
-	// 	if ipCell == nil {
-	// 		continue
-	// 	}
-	// 	ip := strings.TrimSpace(ipCell.Text)
-	// 	if _, ok := activeIPs[ip]; !ok {
-	// 		continue
-	// 	}
-	// 	// ip := resultTable.GetCell(ipIndex, 0)
-	// 	responseTime := pingResult(ip)
-	// 	status, color := ipResponseStatus(responseTime)
-	// 	resStatus := fmt.Sprintf("%s: %.2f ms", ip, responseTime)
-	// 	if responseTime < 0 {
-	// 		resStatus = fmt.Sprintf("%s: Failed", ip)
-	// 	}
-
-	// 	app.QueueUpdateDraw(func() {
-	// 		resultTable.SetCell(row, 1, tview.NewTableCell(status+resStatus).SetTextColor(color).SetAlign(tview.AlignCenter))
-	// 	})
-	// }
-	for ip, rowId := range activeIPs {
-		responseTime := pingResult(ip)
-		status, color := ipResponseStatus(responseTime)
-		resStatus := fmt.Sprintf("%.2f ms", responseTime)
-		if responseTime < 0 {
-			resStatus = "Failed"
+// ProbeEcho is Probe also counting duplicate, reordered and late replies.
+// It returns once every request is answered or timed out. If onLate is not
+// nil the requests keep listening in the background for LateWindow past
+// the timeout, and anomalies noticed after ProbeEcho returned are passed
+// to onLate from the receiving goroutine.
+func ProbeEcho(ip string, s Settings, privileged bool, onLate func(Anomalies)) (time.Duration, Anomalies, error) {
+	count := max(s.Count, 1)
+	listen := s.Timeout
+	if onLate != nil {
+		listen += LateWindow
+	}
+
+	// Every request gets its own pinger that sends once and listens until
+	// stopped, so replies are still seen after the request was answered
+	pingers := make([]*probing.Pinger, count)
+	for i := range pingers {
+		pinger, err := newEchoPinger(ip, s, privileged, listen)
+		if err != nil {
+			log.Printf("Error creating pinger for %s: %v\n", ip, err)
+			return 0, Anomalies{}, fmt.Errorf("failed to create pinger: %w", err)
 		}
+		pingers[i] = pinger
+	}
+
+	var mu sync.Mutex
+	var anomalies Anomalies
+	var total time.Duration
+	recv, latest, returned := 0, -1, false
+	answered := make(chan struct{}, count)
+	failed := make(chan error, count)
+
+	// note counts a, or reports it to onLate once ProbeEcho returned
+	note := func(a Anomalies) {
+		mu.Lock()
+		late := returned
+		if !late {
+			anomalies = anomalies.Add(a)
+		}
+		mu.Unlock()
+		if late && onLate != nil {
+			onLate(a)
+		}
+	}
 
-		log.Printf("Refresh ping for %s (row %d): %s", ip, rowId, resStatus)
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for i, pinger := range pingers {
+		pinger.OnRecv = func(pkt *probing.Packet) {
+			mu.Lock()
+			if returned || pkt.Rtt > s.Timeout {
+				mu.Unlock()
+				note(Anomalies{Late: 1})
+				return
+			}
+			if i < latest {
+				anomalies.Reordered++
+			}
+			latest = max(latest, i)
+			total += pkt.Rtt
+			recv++
+			mu.Unlock()
+			answered <- struct{}{}
+		}
+		pinger.OnDuplicateRecv = func(*probing.Packet) {
+			note(Anomalies{Duplicates: 1})
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-time.After(time.Duration(i) * burstInterval):
+			case <-ctx.Done():
+				return
+			}
+			if err := pinger.RunWithContext(ctx); err != nil && ctx.Err() == nil {
+				failed <- err
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	timer := time.NewTimer(s.Timeout + time.Duration(count-1)*burstInterval)
+	defer timer.Stop()
+	var err error
+	for n := 0; n < count && err == nil; n++ {
+		select {
+		case <-answered:
+		case err = <-failed:
+		case <-timer.C:
+			n = count
+		}
+	}
 
-		app.QueueUpdateDraw(func() {
-			resultTable.SetCell(rowId, 1, tview.NewTableCell(status+resStatus).SetTextColor(color).SetAlign(tview.AlignCenter))
-		})
-		app.Draw()
+	mu.Lock()
+	returned = true
+	rtt, received, result := total, recv, anomalies
+	mu.Unlock()
+	if onLate == nil || err != nil {
+		cancel()
 	}
 
+	if err != nil {
+		log.Printf("Error running pinger for %s: %v\n", ip, err)
+		return 0, result, err
+	}
+	if received == 0 {
+		return 0, result, ErrTimeout
+	}
+	return rtt / time.Duration(received), result, nil
 }
 
-func StopPinging() {
-	mu.Lock()
-	defer mu.Unlock()
-	activeIPs = make(map[string]int)
-	log.Println("Stopped pinging, cleared active IPs")
+// newEchoPinger creates a pinger that sends a single echo request with the
+// settings s and listens for replies until stopped or for listen
+func newEchoPinger(ip string, s Settings, privileged bool, listen time.Duration) (*probing.Pinger, error) {
+	pinger, err := probing.NewPinger(ip)
+	if err != nil {
+		return nil, err
+	}
+	pinger.SetPrivileged(privileged)
+	pinger.Count = -1
+	pinger.Interval = listen + time.Second // Never due again while listening
+	pinger.Timeout = listen
+	pinger.Size = s.Size
+	pinger.TTL = s.TTL
+	source := ipinfo.ParseSource(s.Source)
+	pinger.Source = source.Address
+	if source.Address == "" {
+		pinger.InterfaceName = source.Interface
+	}
+	pinger.SetTrafficClass(uint8(s.DSCP << 2))
+	return pinger, nil
 }
@@ -0,0 +1,102 @@
+package ping
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/icmp"
+)
+
+const pingGroupRangePath = "/proc/sys/net/ipv4/ping_group_range"
+
+// Mode describes how ICMP probes are sent on this host
+type Mode struct {
+	Privileged bool   // Raw ICMP sockets; otherwise datagram ("ping") sockets
+	Reason     string // Why this mode was chosen
+	Err        error  // Set when neither mode is usable
+}
+
+func (m Mode) String() string {
+	name := "unprivileged (datagram ICMP)"
+	if m.Privileged {
+		name = "privileged (raw socket)"
+	}
+	if m.Err != nil {
+		return fmt.Sprintf("%s - %v", name, m.Err)
+	}
+	return fmt.Sprintf("%s - %s", name, m.Reason)
+}
+
+var (
+	detectOnce   sync.Once
+	detectedMode Mode
+)
+
+// DetectMode reports whether raw ICMP sockets are available and otherwise
+// whether the kernel allows unprivileged datagram ICMP. The check runs once
+// per process.
+func DetectMode() Mode {
+	detectOnce.Do(func() {
+		detectedMode = detectMode()
+		log.Printf("ICMP mode: %s", detectedMode)
+	})
+	return detectedMode
+}
+
+func detectMode() Mode {
+	if runtime.GOOS == "windows" {
+		return Mode{Privileged: true, Reason: "Windows always uses raw ICMP"}
+	}
+
+	conn, rawErr := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if rawErr == nil {
+		conn.Close()
+		return Mode{Privileged: true, Reason: "raw sockets available"}
+	}
+
+	if runtime.GOOS == "linux" {
+		if ok, reason := pingGroupAllowed(); !ok {
+			return Mode{
+				Privileged: true,
+				Err:        fmt.Errorf("raw ICMP needs root or CAP_NET_RAW (%v) and %s", rawErr, reason),
+			}
+		}
+	}
+
+	conn, dgramErr := icmp.ListenPacket("udp4", "0.0.0.0")
+	if dgramErr != nil {
+		return Mode{
+			Privileged: true,
+			Err:        fmt.Errorf("raw ICMP unavailable (%v), datagram ICMP unavailable (%v)", rawErr, dgramErr),
+		}
+	}
+	conn.Close()
+	return Mode{Reason: fmt.Sprintf("raw sockets unavailable: %v", rawErr)}
+}
+
+// pingGroupAllowed checks net.ipv4.ping_group_range against the groups of
+// the current process
+func pingGroupAllowed() (bool, string) {
+	data, err := os.ReadFile(pingGroupRangePath)
+	if err != nil {
+		return false, fmt.Sprintf("cannot read %s: %v", pingGroupRangePath, err)
+	}
+
+	var low, high int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d %d", &low, &high); err != nil {
+		return false, fmt.Sprintf("cannot parse %s: %v", pingGroupRangePath, err)
+	}
+
+	groups, _ := os.Getgroups()
+	groups = append(groups, os.Getegid())
+	for _, gid := range groups {
+		if gid >= low && gid <= high {
+			return true, ""
+		}
+	}
+	return false, fmt.Sprintf("net.ipv4.ping_group_range (%d %d) excludes gid %d", low, high, os.Getegid())
+}
@@ -0,0 +1,248 @@
+package ping
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/httpprobe"
+)
+
+// ProbeType selects how a target is probed
+type ProbeType string
+
+const (
+	ICMP ProbeType = "icmp" // ICMP echo request
+	TCP  ProbeType = "tcp"  // TCP connect, timing the handshake
+	UDP  ProbeType = "udp"  // UDP datagram that must be answered
+	HTTP ProbeType = "http" // HTTP or HTTPS request, see package httpprobe
+)
+
+// ErrUnexpectedResponse is reported when a UDP reply does not contain Settings.Expect
+var ErrUnexpectedResponse = errors.New("unexpected response")
+
+// Spec identifies a probe target, written as host, host:tcp/443,
+// host:udp/53 or an http:// or https:// URL. IPv6 literals take a port
+// suffix only in brackets, as in [2001:db8::1]:tcp/443. Apart from URLs a
+// DSCP class may follow a #, as in host#ef, so that one host can be
+// probed in several classes side by side.
+type Spec struct {
+	Host  string
+	Type  ProbeType
+	Port  int
+	URL   string // Set for HTTP probes only
+	Class string // DSCP class name overriding Settings.DSCP, see DSCPName
+}
+
+// ParseSpec parses a target written in the syntax described on Spec
+func ParseSpec(s string) (Spec, error) {
+	s = strings.TrimSpace(s)
+	spec := Spec{Host: s, Type: ICMP}
+
+	if lower := strings.ToLower(s); strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		u, err := url.Parse(s)
+		if err != nil || u.Hostname() == "" {
+			return spec, fmt.Errorf("invalid URL %q", s)
+		}
+		return Spec{Host: u.Hostname(), Type: HTTP, URL: s}, nil
+	}
+
+	if base, class, ok := strings.Cut(s, "#"); ok {
+		dscp, err := ParseDSCP(class)
+		if err != nil {
+			return spec, fmt.Errorf("invalid target %q: %v", s, err)
+		}
+		spec, err := ParseSpec(base)
+		spec.Class = DSCPName(dscp)
+		return spec, err
+	}
+
+	i := strings.LastIndex(s, ":")
+	bracketed := strings.HasPrefix(s, "[")
+	if i < 0 || (!bracketed && strings.Count(s, ":") > 1) {
+		return spec, nil // Plain host or bare IPv6 literal
+	}
+
+	host, suffix := s[:i], strings.ToLower(s[i+1:])
+	if bracketed {
+		if !strings.HasSuffix(host, "]") {
+			return spec, fmt.Errorf("invalid target %q: missing ]", s)
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	spec.Host = host
+
+	proto, port, hasPort := strings.Cut(suffix, "/")
+	switch ProbeType(proto) {
+	case ICMP:
+		if hasPort {
+			return spec, fmt.Errorf("invalid target %q: icmp takes no port", s)
+		}
+		return spec, nil
+	case TCP, UDP:
+		spec.Type = ProbeType(proto)
+	default:
+		return spec, fmt.Errorf("invalid target %q: unknown probe type %q", s, proto)
+	}
+
+	n, err := strconv.Atoi(port)
+	if !hasPort || err != nil || n < 1 || n > 65535 {
+		return spec, fmt.Errorf("invalid target %q: %s needs a port between 1 and 65535", s, proto)
+	}
+	spec.Port = n
+	return spec, nil
+}
+
+// String formats the spec in the syntax accepted by ParseSpec
+func (s Spec) String() string {
+	if s.Type == HTTP {
+		return s.URL
+	}
+	class := ""
+	if s.Class != "" {
+		class = "#" + s.Class
+	}
+	if s.Type == ICMP || s.Type == "" {
+		return s.Host + class
+	}
+	host := s.Host
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s:%s/%d%s", host, s.Type, s.Port, class)
+}
+
+// Label names the probe type for display, e.g. ICMP, TCP/443 or ICMP EF
+func (s Spec) Label() string {
+	if s.Class != "" {
+		return s.typeLabel() + " " + strings.ToUpper(s.Class)
+	}
+	return s.typeLabel()
+}
+
+func (s Spec) typeLabel() string {
+	if s.Type == ICMP || s.Type == "" {
+		return "ICMP"
+	}
+	if s.Type == HTTP {
+		if strings.HasPrefix(strings.ToLower(s.URL), "https://") {
+			return "HTTPS"
+		}
+		return "HTTP"
+	}
+	return fmt.Sprintf("%s/%d", strings.ToUpper(string(s.Type)), s.Port)
+}
+
+// ProbeSpec probes addr, the resolved address of spec.Host, using the
+// method selected by spec.Type. HTTP specs are handled by ProbeHTTP instead.
+// Reply anomalies are only counted for ICMP; see ProbeEcho for onLate.
+func ProbeSpec(spec Spec, addr string, s Settings, privileged bool, onLate func(Anomalies)) (time.Duration, Anomalies, error) {
+	if spec.Class != "" {
+		s.DSCP, _ = ParseDSCP(spec.Class)
+	}
+	var rtt time.Duration
+	var err error
+	switch spec.Type {
+	case TCP:
+		rtt, err = ProbeTCP(addr, spec.Port, s)
+	case UDP:
+		rtt, err = ProbeUDP(addr, spec.Port, s)
+	default:
+		return ProbeEcho(addr, s, privileged, onLate)
+	}
+	return rtt, Anomalies{}, err
+}
+
+// ProbeTCP connects to addr:port and returns the time taken by the handshake
+func ProbeTCP(addr string, port int, s Settings) (time.Duration, error) {
+	start := time.Now()
+	conn, err := dialer("tcp", s).Dial("tcp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	rtt := time.Since(start)
+	if err != nil {
+		return 0, probeError(err)
+	}
+	conn.Close()
+	return rtt, nil
+}
+
+// ProbeUDP sends s.Payload to addr:port and waits for a reply containing
+// s.Expect, or any reply when Expect is empty
+func ProbeUDP(addr string, port int, s Settings) (time.Duration, error) {
+	payload, err := decodePayload(s.Payload)
+	if err != nil {
+		return 0, fmt.Errorf("invalid payload: %w", err)
+	}
+	expect, err := decodePayload(s.Expect)
+	if err != nil {
+		return 0, fmt.Errorf("invalid expected response: %w", err)
+	}
+
+	conn, err := dialer("udp", s).Dial("udp", net.JoinHostPort(addr, strconv.Itoa(port)))
+	if err != nil {
+		return 0, probeError(err)
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	conn.SetDeadline(start.Add(s.Timeout))
+	if _, err := conn.Write(payload); err != nil {
+		return 0, probeError(err)
+	}
+
+	reply := make([]byte, 65535)
+	n, err := conn.Read(reply)
+	rtt := time.Since(start)
+	if err != nil {
+		return 0, probeError(err)
+	}
+	if len(expect) > 0 && !bytes.Contains(reply[:n], expect) {
+		return 0, ErrUnexpectedResponse
+	}
+	return rtt, nil
+}
+
+// ProbeHTTP requests spec.URL and returns its timing breakdown. The client
+// does its own DNS lookup so that resolution time is part of the breakdown.
+func ProbeHTTP(spec Spec, s Settings) (httpprobe.Timing, error) {
+	opts := httpprobe.DefaultOptions()
+	opts.Timeout = s.Timeout
+	opts.ExpectStatus = s.ExpectStatus
+	opts.Dialer = dialer("tcp", s)
+	opts.ExpectBody = s.Expect
+	opts.Headers = make(http.Header)
+	for name, value := range s.Headers {
+		opts.Headers.Set(name, value)
+	}
+
+	timing, err := httpprobe.Probe(spec.URL, opts)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		err = ErrTimeout
+	}
+	return timing, err
+}
+
+// decodePayload turns a configured payload into bytes. A "hex:" prefix
+// marks hex-encoded binary data; anything else is sent as text.
+func decodePayload(s string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(s, "hex:"); ok {
+		return hex.DecodeString(strings.ReplaceAll(rest, " ", ""))
+	}
+	return []byte(s), nil
+}
+
+// probeError maps socket errors to the short forms shown in the Ping table
+func probeError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrTimeout
+	}
+	return err
+}
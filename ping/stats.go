@@ -0,0 +1,129 @@
+package ping
+
+import (
+	"math"
+	"time"
+)
+
+// Statistics summarises the probe results of one target
+type Statistics struct {
+	Sent      int
+	Recv      int
+	Loss      float64 // Percentage of probes without a reply
+	MinRTT    time.Duration
+	AvgRTT    time.Duration
+	MaxRTT    time.Duration
+	StdDevRTT time.Duration
+	Jitter    time.Duration // Interarrival jitter as defined in RFC 3550
+	Anomalies Anomalies     // Duplicate, reordered and late replies
+}
+
+// accumulator keeps running statistics without storing every sample
+type accumulator struct {
+	sent, recv int
+	min, max   time.Duration
+	mean, m2   float64 // Welford's running mean and sum of squares, in ns
+	jitter     float64
+	last       time.Duration
+	hasLast    bool
+	anomalies  Anomalies
+}
+
+func (a *accumulator) add(res Result) {
+	a.sent++
+	a.anomalies = a.anomalies.Add(res.Anomalies)
+	if res.Err != nil {
+		return
+	}
+	a.recv++
+
+	rtt := res.RTT
+	if a.recv == 1 || rtt < a.min {
+		a.min = rtt
+	}
+	if rtt > a.max {
+		a.max = rtt
+	}
+
+	x := float64(rtt)
+	delta := x - a.mean
+	a.mean += delta / float64(a.recv)
+	a.m2 += delta * (x - a.mean)
+
+	// RFC 3550 section 6.4.1: J += (|D| - J) / 16
+	if a.hasLast {
+		d := math.Abs(float64(rtt - a.last))
+		a.jitter += (d - a.jitter) / 16
+	}
+	a.last = rtt
+	a.hasLast = true
+}
+
+func (a *accumulator) statistics() Statistics {
+	s := Statistics{Sent: a.sent, Recv: a.recv, Anomalies: a.anomalies}
+	if a.sent > 0 {
+		s.Loss = float64(a.sent-a.recv) / float64(a.sent) * 100
+	}
+	if a.recv > 0 {
+		s.MinRTT = a.min
+		s.MaxRTT = a.max
+		s.AvgRTT = time.Duration(a.mean)
+		s.StdDevRTT = time.Duration(math.Sqrt(a.m2 / float64(a.recv)))
+		s.Jitter = time.Duration(a.jitter)
+	}
+	return s
+}
+
+// Stats accumulates results for a target over the whole session and over a
+// sliding window of the most recent probes
+type Stats struct {
+	session accumulator
+	window  []Result
+	next    int
+	filled  bool
+}
+
+// NewStats creates a Stats whose sliding window holds size results
+func NewStats(size int) *Stats {
+	if size < 1 {
+		size = 1
+	}
+	return &Stats{window: make([]Result, size)}
+}
+
+// Add records a probe result
+func (s *Stats) Add(res Result) {
+	s.session.add(res)
+	s.window[s.next] = res
+	s.next = (s.next + 1) % len(s.window)
+	if s.next == 0 {
+		s.filled = true
+	}
+}
+
+// AddAnomalies records anomalies noticed after the result of their probe
+// was added. The window counts them with its most recent result.
+func (s *Stats) AddAnomalies(a Anomalies) {
+	s.session.anomalies = s.session.anomalies.Add(a)
+	last := (s.next + len(s.window) - 1) % len(s.window)
+	s.window[last].Anomalies = s.window[last].Anomalies.Add(a)
+}
+
+// Session returns statistics over every result recorded so far
+func (s *Stats) Session() Statistics {
+	return s.session.statistics()
+}
+
+// Window returns statistics over the most recent results only
+func (s *Stats) Window() Statistics {
+	var a accumulator
+	if s.filled {
+		for _, res := range s.window[s.next:] {
+			a.add(res)
+		}
+	}
+	for _, res := range s.window[:s.next] {
+		a.add(res)
+	}
+	return a.statistics()
+}
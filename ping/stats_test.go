@@ -0,0 +1,142 @@
+package ping
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// results turns RTTs in milliseconds into probe results, a negative RTT
+// standing for a lost probe
+func results(rtts ...float64) []Result {
+	var out []Result
+	for _, ms := range rtts {
+		if ms < 0 {
+			out = append(out, Result{Err: ErrTimeout})
+			continue
+		}
+		out = append(out, Result{RTT: time.Duration(ms * float64(time.Millisecond))})
+	}
+	return out
+}
+
+// near reports whether two durations differ by less than a microsecond
+func near(a, b time.Duration) bool {
+	return (a - b).Abs() < time.Microsecond
+}
+
+func ms(v float64) time.Duration {
+	return time.Duration(v * float64(time.Millisecond))
+}
+
+func TestStatistics(t *testing.T) {
+	tests := []struct {
+		name    string
+		rtts    []float64
+		want    Statistics
+		session bool // Compare Session rather than Window of a 2-result window
+	}{
+		{
+			name:    "empty",
+			session: true,
+			want:    Statistics{},
+		},
+		{
+			name:    "steady",
+			rtts:    []float64{10, 10, 10},
+			session: true,
+			want:    Statistics{Sent: 3, Recv: 3, MinRTT: ms(10), AvgRTT: ms(10), MaxRTT: ms(10)},
+		},
+		{
+			// Population stddev of 10, 20, 30 is sqrt(200/3); jitter is
+			// 10/16 after the second reply, then 0.625 + (10-0.625)/16
+			name:    "rising",
+			rtts:    []float64{10, 20, 30},
+			session: true,
+			want: Statistics{Sent: 3, Recv: 3, MinRTT: ms(10), AvgRTT: ms(20), MaxRTT: ms(30),
+				StdDevRTT: ms(8.164966), Jitter: ms(1.2109375)},
+		},
+		{
+			name:    "losses",
+			rtts:    []float64{-1, 40, -1, 20},
+			session: true,
+			want: Statistics{Sent: 4, Recv: 2, Loss: 50, MinRTT: ms(20), AvgRTT: ms(30), MaxRTT: ms(40),
+				StdDevRTT: ms(10), Jitter: ms(1.25)},
+		},
+		{
+			name: "all lost",
+			rtts: []float64{-1, -1},
+			want: Statistics{Sent: 2, Loss: 100},
+		},
+		{
+			name: "window keeps the last two",
+			rtts: []float64{100, 20, 30},
+			want: Statistics{Sent: 2, Recv: 2, MinRTT: ms(20), AvgRTT: ms(25), MaxRTT: ms(30),
+				StdDevRTT: ms(5), Jitter: ms(0.625)},
+		},
+		{
+			name: "window before it fills",
+			rtts: []float64{-1},
+			want: Statistics{Sent: 1, Loss: 100},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStats(2)
+			for _, res := range results(tt.rtts...) {
+				s.Add(res)
+			}
+			got := s.Window()
+			if tt.session {
+				got = s.Session()
+			}
+			if got.Sent != tt.want.Sent || got.Recv != tt.want.Recv || got.Loss != tt.want.Loss {
+				t.Errorf("sent/recv/loss = %d/%d/%.1f, want %d/%d/%.1f",
+					got.Sent, got.Recv, got.Loss, tt.want.Sent, tt.want.Recv, tt.want.Loss)
+			}
+			for _, d := range []struct {
+				name      string
+				got, want time.Duration
+			}{
+				{"min", got.MinRTT, tt.want.MinRTT},
+				{"avg", got.AvgRTT, tt.want.AvgRTT},
+				{"max", got.MaxRTT, tt.want.MaxRTT},
+				{"stddev", got.StdDevRTT, tt.want.StdDevRTT},
+				{"jitter", got.Jitter, tt.want.Jitter},
+			} {
+				if !near(d.got, d.want) {
+					t.Errorf("%s = %v, want %v", d.name, d.got, d.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStatisticsAnomalies(t *testing.T) {
+	s := NewStats(1)
+	s.Add(Result{RTT: ms(1), Anomalies: Anomalies{Duplicates: 2}})
+	s.Add(Result{Err: errors.New("lost"), Anomalies: Anomalies{Late: 1}})
+
+	if got, want := s.Session().Anomalies, (Anomalies{Duplicates: 2, Late: 1}); got != want {
+		t.Errorf("session anomalies = %+v, want %+v", got, want)
+	}
+	if got, want := s.Window().Anomalies, (Anomalies{Late: 1}); got != want {
+		t.Errorf("window anomalies = %+v, want %+v", got, want)
+	}
+}
+
+func TestStatsAddAnomalies(t *testing.T) {
+	s := NewStats(2)
+	s.AddAnomalies(Anomalies{Late: 1}) // Before any result
+	s.Add(Result{RTT: ms(1)})
+	s.Add(Result{RTT: ms(2)})
+	s.AddAnomalies(Anomalies{Reordered: 1, Late: 1})
+	s.Add(Result{RTT: ms(3)})
+
+	if got, want := s.Session().Anomalies, (Anomalies{Reordered: 1, Late: 2}); got != want {
+		t.Errorf("session anomalies = %+v, want %+v", got, want)
+	}
+	if got, want := s.Window().Anomalies, (Anomalies{Reordered: 1, Late: 1}); got != want {
+		t.Errorf("window anomalies = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package ping
+
+import (
+	"os"
+	"syscall"
+)
+
+// setTOS sets the IPv4 TOS byte or IPv6 traffic class of a socket
+func setTOS(c syscall.RawConn, ipv6 bool, tos int) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		if ipv6 {
+			opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+		} else {
+			opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", opErr)
+}
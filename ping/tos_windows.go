@@ -0,0 +1,28 @@
+//go:build windows
+
+package ping
+
+import (
+	"os"
+	"syscall"
+)
+
+// ipv6TClass is IPV6_TCLASS, missing from package syscall on Windows
+const ipv6TClass = 39
+
+// setTOS sets the IPv4 TOS byte or IPv6 traffic class of a socket. Windows
+// only honours it when a QoS policy allows applications to mark packets.
+func setTOS(c syscall.RawConn, ipv6 bool, tos int) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		if ipv6 {
+			opErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IPV6, ipv6TClass, tos)
+		} else {
+			opErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", opErr)
+}
@@ -0,0 +1,27 @@
+//go:build linux
+
+package pmtu
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// setDontFragment sets the DF flag on outgoing packets. Probe mode also
+// ignores the path MTU the kernel has cached, so every size is really sent.
+func setDontFragment(c syscall.RawConn) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+	})
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", opErr)
+}
+
+// isMsgSize reports whether a send failed because the packet exceeds the interface MTU
+func isMsgSize(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
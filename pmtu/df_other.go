@@ -0,0 +1,20 @@
+//go:build !linux && !windows
+
+package pmtu
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// setDontFragment is only implemented on Linux and Windows
+func setDontFragment(c syscall.RawConn) error {
+	return fmt.Errorf("setting the DF flag is not supported on %s", runtime.GOOS)
+}
+
+// isMsgSize reports whether a send failed because the packet exceeds the interface MTU
+func isMsgSize(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE)
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package pmtu
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+const (
+	ipDontFragment = 14    // IP_DONTFRAGMENT
+	wsaEMsgSize    = 10040 // WSAEMSGSIZE
+)
+
+// setDontFragment sets the DF flag on outgoing packets
+func setDontFragment(c syscall.RawConn) error {
+	var opErr error
+	err := c.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.IPPROTO_IP, ipDontFragment, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return os.NewSyscallError("setsockopt", opErr)
+}
+
+// isMsgSize reports whether a send failed because the packet exceeds the interface MTU
+func isMsgSize(err error) bool {
+	return errors.Is(err, syscall.Errno(wsaEMsgSize))
+}
@@ -0,0 +1,442 @@
+package pmtu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/rivo/tview"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// Probe sizes are whole IPv4 packets, headers included
+const (
+	MinSize        = 68    // Smallest MTU every IPv4 link must carry
+	MaxSize        = 65535 // Largest IPv4 packet
+	defaultLimit   = 1500  // Upper bound when the outgoing interface is unknown
+	headerLen      = 20 + 8
+	codeFragNeeded = 4 // Destination unreachable code for "Fragmentation needed and DF set"
+)
+
+// FragNeeded is a "Fragmentation needed" message received for a probe
+type FragNeeded struct {
+	From string `json:"from"`          // Router that dropped the probe, "local" for the own interface
+	TTL  int    `json:"ttl,omitempty"` // Hop of From, when the path was traced
+	MTU  int    `json:"mtu"`           // Next-hop MTU reported by the router, 0 if it sent none
+	Size int    `json:"size"`          // Size of the probe that was too big
+}
+
+// Hop is the largest packet that reached one hop of the path
+type Hop struct {
+	TTL     int    `json:"ttl"`
+	IP      string `json:"ip"`
+	MTU     int    `json:"mtu"`
+	Timeout bool   `json:"timeout"`
+}
+
+// Result is the outcome of a path MTU discovery
+type Result struct {
+	Dest         string       `json:"dest"`
+	PathMTU      int          `json:"path_mtu"` // 0 when not even the smallest probe was answered
+	Interface    string       `json:"interface"`
+	InterfaceMTU int          `json:"interface_mtu"`
+	Probes       int          `json:"probes"`
+	FragNeeded   []FragNeeded `json:"frag_needed"`
+	Blackhole    bool         `json:"blackhole"`             // Probes vanished without a "Fragmentation needed" message
+	Unreachable  string       `json:"unreachable,omitempty"` // Other "Destination unreachable" message received
+	Hops         []Hop        `json:"hops,omitempty"`
+}
+
+// Discoverer finds the path MTU to a destination by sending ICMP echo
+// requests with the DF flag set, bisecting their size
+type Discoverer struct {
+	DestIP     string
+	Timeout    time.Duration // Time to wait for the answer to each probe
+	Tries      int           // Probes of one size before it counts as lost
+	PerHop     bool          // Also find the MTU up to every hop of the path
+	MaxHops    int
+	Source     ipinfo.Source
+	id         int
+	seq        int
+	result     Result
+	text       strings.Builder
+	resultView *tview.TextView
+	app        *tview.Application
+}
+
+// conn is the raw ICMP socket probes are sent on
+type conn interface {
+	net.PacketConn
+	setTTL(ttl int) error
+}
+
+type rawConn struct{ net.PacketConn }
+
+func (c rawConn) setTTL(ttl int) error { return ipv4.NewPacketConn(c.PacketConn).SetTTL(ttl) }
+
+// answer is what came back for one probe
+type answer int
+
+const (
+	lost        answer = iota
+	fits               // Echo reply, or Time exceeded when the TTL is limited
+	tooBig             // "Fragmentation needed", or the packet did not fit the own interface
+	unreachable        // Any other "Destination unreachable"
+)
+
+// NewDiscoverer creates a Discoverer for the IPv4 address destIP. app and
+// resultView may be nil to run without a TUI; see Result.
+func NewDiscoverer(destIP string, app *tview.Application, resultView *tview.TextView) (*Discoverer, error) {
+	ip := net.ParseIP(destIP)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid destination IP: %s", destIP)
+	}
+	if ip.To4() == nil {
+		return nil, fmt.Errorf("path MTU discovery supports IPv4 destinations only")
+	}
+	return &Discoverer{
+		DestIP:     ip.To4().String(), // Canonical text, as reply addresses are reported
+		Timeout:    2 * time.Second,
+		Tries:      2,
+		MaxHops:    30,
+		id:         os.Getpid() & 0xffff,
+		app:        app,
+		resultView: resultView,
+	}, nil
+}
+
+// Result returns the outcome of Run
+func (d *Discoverer) Result() Result {
+	return d.result
+}
+
+// Run discovers the path MTU and, with PerHop, the MTU up to every hop
+func (d *Discoverer) Run() error {
+	d.result = Result{Dest: d.DestIP}
+	d.text.Reset()
+
+	d.result.Interface, d.result.InterfaceMTU = d.outgoingInterface()
+	limit := defaultLimit
+	if d.result.InterfaceMTU > 0 {
+		limit = min(d.result.InterfaceMTU, MaxSize)
+		d.addLine(fmt.Sprintf("Path MTU to %s via %s (interface MTU %d)", d.DestIP, d.result.Interface, d.result.InterfaceMTU))
+	} else {
+		d.addLine(fmt.Sprintf("Path MTU to %s (outgoing interface unknown, trying up to %d)", d.DestIP, limit))
+	}
+
+	conn, err := d.listen()
+	if err != nil {
+		d.addLine(fmt.Sprintf("Failed to listen for ICMP: %v (run with admin privileges?)", err))
+		return err
+	}
+	defer conn.Close()
+
+	if d.PerHop {
+		err = d.runHops(conn, limit)
+	} else {
+		err = d.runPath(conn, limit)
+	}
+	if err != nil {
+		d.addLine(fmt.Sprintf("Path MTU discovery failed: %v", err))
+		return err
+	}
+	d.addLine(d.summary())
+	log.Printf("Path MTU to %s: %d (%d probes)", d.DestIP, d.result.PathMTU, d.result.Probes)
+	return nil
+}
+
+// runPath bisects the size of probes to the destination
+func (d *Discoverer) runPath(conn conn, limit int) error {
+	ans, _, err := d.try(conn, MinSize, 0)
+	if err != nil {
+		return err
+	}
+	switch ans {
+	case unreachable:
+		d.addLine(d.result.Unreachable)
+		return nil
+	case lost:
+		d.addLine(fmt.Sprintf("No reply to %d byte probes; the destination does not answer ICMP echo", MinSize))
+		return nil
+	}
+	d.result.PathMTU, err = d.largest(conn, 0, MinSize, limit)
+	return err
+}
+
+// runHops finds the largest probe reaching each hop in turn. The MTU up to
+// a hop never exceeds the one up to the hop before, so each hop usually
+// takes a single probe.
+func (d *Discoverer) runHops(conn conn, limit int) error {
+	for ttl := 1; ttl <= d.MaxHops; ttl++ {
+		ans, from, err := d.try(conn, MinSize, ttl)
+		if err != nil {
+			return err
+		}
+		if ans == unreachable {
+			d.addLine(d.result.Unreachable)
+			break
+		}
+		if ans != fits {
+			d.result.Hops = append(d.result.Hops, Hop{TTL: ttl, IP: "*", Timeout: true})
+			d.addLine(fmt.Sprintf("Hop %2d: *", ttl))
+			continue
+		}
+		if limit, err = d.largest(conn, ttl, MinSize, limit); err != nil {
+			return err
+		}
+		d.result.Hops = append(d.result.Hops, Hop{TTL: ttl, IP: from, MTU: limit})
+		d.addLine(fmt.Sprintf("Hop %2d: %s - MTU %d", ttl, from, limit))
+		if net.ParseIP(from).Equal(net.ParseIP(d.DestIP)) {
+			d.result.PathMTU = limit
+			break
+		}
+	}
+
+	for i, frag := range d.result.FragNeeded {
+		for _, hop := range d.result.Hops {
+			if hop.IP == frag.From {
+				d.result.FragNeeded[i].TTL = hop.TTL
+			}
+		}
+	}
+	return nil
+}
+
+// largest returns the largest size up to limit that fits, good being a
+// size known to fit. A "Fragmentation needed" message with a next-hop MTU
+// is tried directly instead of bisecting further.
+func (d *Discoverer) largest(conn conn, ttl, good, limit int) (int, error) {
+	bad := limit + 1
+	next := limit
+	for good < bad-1 {
+		ans, from, err := d.try(conn, next, ttl)
+		if err != nil {
+			return good, err
+		}
+
+		switch ans {
+		case fits:
+			good = next
+			d.addLine(fmt.Sprintf("  %5d bytes: ok", next))
+		case tooBig:
+			bad = next
+			frag := d.result.FragNeeded[len(d.result.FragNeeded)-1]
+			d.addLine(fmt.Sprintf("  %5d bytes: Fragmentation needed from %s%s", next, from, mtuText(frag.MTU)))
+			if frag.MTU > good && frag.MTU < bad {
+				// Nothing larger than the reported MTU passes that router
+				bad = frag.MTU + 1
+				next = frag.MTU
+				continue
+			}
+		case unreachable:
+			bad = next
+			d.addLine(fmt.Sprintf("  %5d bytes: %s", next, d.result.Unreachable))
+		default:
+			bad = next
+			d.result.Blackhole = true
+			d.addLine(fmt.Sprintf("  %5d bytes: no answer", next))
+		}
+		next = (good + bad) / 2
+	}
+	return good, nil
+}
+
+// try sends probes of size until one is answered, at most Tries times
+func (d *Discoverer) try(conn conn, size, ttl int) (answer, string, error) {
+	for i := 0; i < max(d.Tries, 1); i++ {
+		ans, from, err := d.probe(conn, size, ttl)
+		if err != nil || ans != lost {
+			return ans, from, err
+		}
+	}
+	return lost, "", nil
+}
+
+// probe sends one echo request of size bytes with the DF flag set and
+// waits for the answer, skipping ICMP messages that belong to other
+// probes. ttl 0 leaves the default TTL.
+func (d *Discoverer) probe(conn conn, size, ttl int) (answer, string, error) {
+	if ttl == 0 {
+		ttl = 64
+	}
+	if err := conn.setTTL(ttl); err != nil {
+		return lost, "", fmt.Errorf("failed to set TTL: %w", err)
+	}
+
+	d.seq = (d.seq + 1) & 0xffff
+	d.result.Probes++
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: d.id, Seq: d.seq, Data: make([]byte, size-headerLen)},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return lost, "", fmt.Errorf("failed to marshal ICMP message: %w", err)
+	}
+
+	deadline := time.Now().Add(d.Timeout)
+	if _, err := conn.WriteTo(b, &net.IPAddr{IP: net.ParseIP(d.DestIP)}); err != nil {
+		if isMsgSize(err) {
+			d.result.FragNeeded = append(d.result.FragNeeded, FragNeeded{From: "local", MTU: d.result.InterfaceMTU, Size: size})
+			return tooBig, "local", nil
+		}
+		return lost, "", fmt.Errorf("failed to send ICMP packet: %w", err)
+	}
+
+	reply := make([]byte, 1500)
+	conn.SetReadDeadline(deadline)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return lost, "", nil
+			}
+			return lost, "", fmt.Errorf("failed to read ICMP reply: %w", err)
+		}
+		from := peer.(*net.IPAddr).IP.String()
+
+		rm, err := icmp.ParseMessage(1, reply[:n])
+		if err != nil {
+			continue
+		}
+		switch body := rm.Body.(type) {
+		case *icmp.Echo:
+			if rm.Type == ipv4.ICMPTypeEchoReply && body.ID == d.id && body.Seq == d.seq {
+				return fits, from, nil
+			}
+		case *icmp.TimeExceeded:
+			if d.matches(body.Data) {
+				return fits, from, nil
+			}
+		case *icmp.DstUnreach:
+			if !d.matches(body.Data) {
+				continue
+			}
+			if rm.Code != codeFragNeeded {
+				d.result.Unreachable = fmt.Sprintf("Destination unreachable (code %d) from %s", rm.Code, from)
+				return unreachable, from, nil
+			}
+			// The next-hop MTU is in the low half of the otherwise unused word
+			mtu := int(reply[6])<<8 | int(reply[7])
+			d.result.FragNeeded = append(d.result.FragNeeded, FragNeeded{From: from, MTU: mtu, Size: size})
+			return tooBig, from, nil
+		}
+	}
+}
+
+// matches reports whether data, the start of a packet quoted in an ICMP
+// error, is the echo request sent last
+func (d *Discoverer) matches(data []byte) bool {
+	if len(data) < ipv4.HeaderLen {
+		return false
+	}
+	ihl := int(data[0]&0x0f) * 4
+	if len(data) < ihl+8 || data[9] != 1 || !net.IP(data[16:20]).Equal(net.ParseIP(d.DestIP)) {
+		return false
+	}
+	echo := data[ihl:]
+	id, seq := int(echo[4])<<8|int(echo[5]), int(echo[6])<<8|int(echo[7])
+	return echo[0] == byte(ipv4.ICMPTypeEcho) && id == d.id && seq == d.seq
+}
+
+// listen opens the raw ICMP socket, sending with the DF flag set
+func (d *Discoverer) listen() (conn, error) {
+	lc := d.Source.ListenConfig()
+	bind := lc.Control
+	lc.Control = func(network, address string, c syscall.RawConn) error {
+		if bind != nil {
+			if err := bind(network, address, c); err != nil {
+				return err
+			}
+		}
+		return setDontFragment(c)
+	}
+	pc, err := lc.ListenPacket(context.Background(), "ip4:icmp", d.Source.ListenAddress(false))
+	if err != nil {
+		return nil, err
+	}
+	return rawConn{pc}, nil
+}
+
+// outgoingInterface returns the name and MTU of the interface probes to
+// the destination leave from, as listed by ipinfo.GetIpDetails
+func (d *Discoverer) outgoingInterface() (string, int) {
+	details, err := ipinfo.GetIpDetails()
+	if err != nil {
+		log.Printf("Failed to fetch interfaces: %v", err)
+		return "", 0
+	}
+	if d.Source.Interface != "" && d.Source.Address == "" {
+		for _, detail := range details {
+			if detail.Name == d.Source.Interface {
+				return detail.Name, detail.MTU
+			}
+		}
+		return "", 0
+	}
+
+	// Connecting a UDP socket picks the route without sending anything
+	conn, err := d.Source.Dialer("udp4", d.Timeout).Dial("udp4", net.JoinHostPort(d.DestIP, "9"))
+	if err != nil {
+		log.Printf("Failed to find the route to %s: %v", d.DestIP, err)
+		return "", 0
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+	for _, detail := range details {
+		for _, cidr := range detail.IPs {
+			if ip, _, err := net.ParseCIDR(cidr); err == nil && ip.Equal(local) {
+				return detail.Name, detail.MTU
+			}
+		}
+	}
+	return "", 0
+}
+
+// summary compares the path MTU with the interface MTU
+func (d *Discoverer) summary() string {
+	r := d.result
+	if r.PathMTU == 0 {
+		return "Path MTU unknown"
+	}
+	text := fmt.Sprintf("Path MTU: %d bytes", r.PathMTU)
+	switch {
+	case r.InterfaceMTU == 0:
+	case r.PathMTU < r.InterfaceMTU:
+		text += fmt.Sprintf(", %d below the %s MTU of %d", r.InterfaceMTU-r.PathMTU, r.Interface, r.InterfaceMTU)
+	default:
+		text += fmt.Sprintf(", the full %s MTU", r.Interface)
+	}
+	if r.Blackhole {
+		text += "\nSome probes vanished without a Fragmentation needed message: possible MTU blackhole"
+	}
+	return text
+}
+
+func mtuText(mtu int) string {
+	if mtu == 0 {
+		return " (no MTU given)"
+	}
+	return fmt.Sprintf(" (next-hop MTU %d)", mtu)
+}
+
+// addLine appends a line to the output and updates the TUI
+func (d *Discoverer) addLine(line string) {
+	d.text.WriteString(line + "\n")
+	if d.app == nil {
+		return
+	}
+	text := d.text.String()
+	d.app.QueueUpdateDraw(func() {
+		d.resultView.SetText(text)
+	})
+}
@@ -0,0 +1,287 @@
+package pmtu
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// router is a hop of a fakePath
+type router struct {
+	ip       string
+	mtu      int  // MTU of the link after the router, 0 for no limit
+	silent   bool // Drops probes that are too big without telling (blackhole)
+	reported int  // Next-hop MTU put in Fragmentation needed, -1 for the real one
+}
+
+// fakePath is a socket answering echo requests like a path of routers
+// followed by the destination would
+type fakePath struct {
+	dest    string
+	routers []router
+	noise   bool // Queue an unrelated ICMP message before every answer
+	ttl     int
+	queue   []fakePacket
+}
+
+type fakePacket struct {
+	data []byte
+	from string
+}
+
+func (p *fakePath) setTTL(ttl int) error {
+	p.ttl = ttl
+	return nil
+}
+
+func (p *fakePath) WriteTo(b []byte, addr net.Addr) (int, error) {
+	msg, err := icmp.ParseMessage(1, b)
+	if err != nil {
+		return 0, err
+	}
+	echo := msg.Body.(*icmp.Echo)
+	size := ipv4.HeaderLen + len(b)
+	// The router or destination quotes the IP header and 8 bytes of the probe
+	quoted := make([]byte, ipv4.HeaderLen, ipv4.HeaderLen+8)
+	quoted[0], quoted[9] = 4<<4|5, 1
+	binary.BigEndian.PutUint16(quoted[2:], uint16(size))
+	copy(quoted[16:], addr.(*net.IPAddr).IP.To4())
+	quoted = append(quoted, b[:8]...)
+
+	if p.noise {
+		other := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: echo.ID + 1, Seq: echo.Seq}}
+		p.queue = append(p.queue, fakePacket{marshal(other), p.dest})
+	}
+	for i, r := range p.routers {
+		if i+1 == p.ttl {
+			p.queue = append(p.queue, fakePacket{marshal(icmp.Message{Type: ipv4.ICMPTypeTimeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}), r.ip})
+			return len(b), nil
+		}
+		if r.mtu > 0 && size > r.mtu {
+			if r.silent {
+				return len(b), nil
+			}
+			frag := marshal(icmp.Message{Type: ipv4.ICMPTypeDestinationUnreachable, Code: codeFragNeeded, Body: &icmp.DstUnreach{Data: quoted}})
+			mtu := r.mtu
+			if r.reported >= 0 {
+				mtu = r.reported
+			}
+			binary.BigEndian.PutUint16(frag[6:], uint16(mtu))
+			p.queue = append(p.queue, fakePacket{frag, r.ip})
+			return len(b), nil
+		}
+	}
+	reply := icmp.Message{Type: ipv4.ICMPTypeEchoReply, Body: &icmp.Echo{ID: echo.ID, Seq: echo.Seq, Data: echo.Data}}
+	p.queue = append(p.queue, fakePacket{marshal(reply), p.dest})
+	return len(b), nil
+}
+
+func (p *fakePath) ReadFrom(b []byte) (int, net.Addr, error) {
+	if len(p.queue) == 0 {
+		return 0, nil, os.ErrDeadlineExceeded
+	}
+	pkt := p.queue[0]
+	p.queue = p.queue[1:]
+	return copy(b, pkt.data), &net.IPAddr{IP: net.ParseIP(pkt.from)}, nil
+}
+
+func (p *fakePath) Close() error                     { return nil }
+func (p *fakePath) LocalAddr() net.Addr              { return &net.IPAddr{} }
+func (p *fakePath) SetDeadline(time.Time) error      { return nil }
+func (p *fakePath) SetReadDeadline(time.Time) error  { return nil }
+func (p *fakePath) SetWriteDeadline(time.Time) error { return nil }
+
+func marshal(msg icmp.Message) []byte {
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func newTestDiscoverer(t *testing.T, dest string) *Discoverer {
+	t.Helper()
+	d, err := NewDiscoverer(dest, nil, nil)
+	if err != nil {
+		t.Fatalf("NewDiscoverer(%q) failed: %v", dest, err)
+	}
+	d.Tries = 1
+	return d
+}
+
+func TestLargest(t *testing.T) {
+	tests := []struct {
+		name          string
+		routers       []router
+		noise         bool
+		want          int
+		wantBlackhole bool
+		wantFrag      []FragNeeded // First Fragmentation needed messages
+		maxProbes     int
+	}{
+		{
+			name:      "full path",
+			routers:   []router{{ip: "10.0.0.1", mtu: 1500}},
+			want:      1500,
+			maxProbes: 1,
+		},
+		{
+			name:      "reported mtu",
+			routers:   []router{{ip: "10.0.0.1", mtu: 1500}, {ip: "10.0.1.1", mtu: 1400, reported: -1}},
+			want:      1400,
+			wantFrag:  []FragNeeded{{From: "10.0.1.1", MTU: 1400, Size: 1500}},
+			maxProbes: 2,
+		},
+		{
+			name:     "no mtu reported",
+			routers:  []router{{ip: "10.0.1.1", mtu: 1400}},
+			want:     1400,
+			wantFrag: []FragNeeded{{From: "10.0.1.1", MTU: 0, Size: 1500}},
+		},
+		{
+			name:    "bogus mtu reported",
+			routers: []router{{ip: "10.0.1.1", mtu: 1400, reported: 1600}},
+			want:    1400,
+		},
+		{
+			name:          "blackhole",
+			routers:       []router{{ip: "10.0.1.1", mtu: 1280, silent: true}},
+			want:          1280,
+			wantBlackhole: true,
+		},
+		{
+			name:      "unrelated replies skipped",
+			routers:   []router{{ip: "10.0.1.1", mtu: 1400, reported: -1}},
+			noise:     true,
+			want:      1400,
+			maxProbes: 2,
+		},
+		{
+			name:    "smallest only",
+			routers: []router{{ip: "10.0.1.1", mtu: MinSize, reported: -1}},
+			want:    MinSize,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDiscoverer(t, "192.0.2.9")
+			path := &fakePath{dest: "192.0.2.9", routers: tt.routers, noise: tt.noise}
+			got, err := d.largest(path, 0, MinSize, 1500)
+			if err != nil {
+				t.Fatalf("largest failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("largest = %d, want %d", got, tt.want)
+			}
+			if d.result.Blackhole != tt.wantBlackhole {
+				t.Errorf("Blackhole = %v, want %v", d.result.Blackhole, tt.wantBlackhole)
+			}
+			for i, want := range tt.wantFrag {
+				if i >= len(d.result.FragNeeded) || d.result.FragNeeded[i] != want {
+					t.Errorf("FragNeeded = %+v, want %+v first", d.result.FragNeeded, tt.wantFrag)
+					break
+				}
+			}
+			if tt.maxProbes > 0 && d.result.Probes > tt.maxProbes {
+				t.Errorf("took %d probes, want at most %d", d.result.Probes, tt.maxProbes)
+			}
+		})
+	}
+}
+
+func TestRunHops(t *testing.T) {
+	// Written as an IPv4-mapped IPv6 address, the destination must still
+	// be recognised when it answers
+	d := newTestDiscoverer(t, "::ffff:192.0.2.9")
+	d.MaxHops = 5
+	path := &fakePath{dest: "192.0.2.9", routers: []router{
+		{ip: "10.0.0.1", mtu: 1500},
+		{ip: "10.0.1.1", mtu: 1400, reported: -1},
+		{ip: "10.0.2.1", mtu: 1400},
+	}}
+	if err := d.runHops(path, 1500); err != nil {
+		t.Fatalf("runHops failed: %v", err)
+	}
+
+	want := []Hop{
+		{TTL: 1, IP: "10.0.0.1", MTU: 1500},
+		{TTL: 2, IP: "10.0.1.1", MTU: 1500},
+		{TTL: 3, IP: "10.0.2.1", MTU: 1400},
+		{TTL: 4, IP: "192.0.2.9", MTU: 1400},
+	}
+	if len(d.result.Hops) != len(want) {
+		t.Fatalf("Hops = %+v, want %+v", d.result.Hops, want)
+	}
+	for i := range want {
+		if d.result.Hops[i] != want[i] {
+			t.Errorf("hop %d = %+v, want %+v", i+1, d.result.Hops[i], want[i])
+		}
+	}
+	if d.result.PathMTU != 1400 {
+		t.Errorf("PathMTU = %d, want 1400", d.result.PathMTU)
+	}
+	if len(d.result.FragNeeded) == 0 || d.result.FragNeeded[0].TTL != 2 {
+		t.Errorf("FragNeeded = %+v, want a message from hop 2", d.result.FragNeeded)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	d := newTestDiscoverer(t, "192.0.2.9")
+	d.seq = 7
+	// quoted returns a quoted echo request with the given header length,
+	// protocol, destination, identifier and sequence number
+	quoted := func(ihl int, proto byte, dest string, id, seq int) []byte {
+		b := make([]byte, ihl)
+		b[0], b[9] = 4<<4|byte(ihl/4), proto
+		copy(b[16:], net.ParseIP(dest).To4())
+		return append(b, byte(ipv4.ICMPTypeEcho), 0, 0, 0, byte(id>>8), byte(id), byte(seq>>8), byte(seq))
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"probe", quoted(20, 1, "192.0.2.9", d.id, 7), true},
+		{"ip options", quoted(24, 1, "192.0.2.9", d.id, 7), true},
+		{"earlier probe", quoted(20, 1, "192.0.2.9", d.id, 6), false},
+		{"other process", quoted(20, 1, "192.0.2.9", d.id^1, 7), false},
+		{"other destination", quoted(20, 1, "192.0.2.10", d.id, 7), false},
+		{"udp", quoted(20, 17, "192.0.2.9", d.id, 7), false},
+		{"truncated", quoted(20, 1, "192.0.2.9", d.id, 7)[:26], false},
+		{"no header", []byte{0x45, 0}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := d.matches(tt.data); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDiscoverer(t *testing.T) {
+	for dest, want := range map[string]string{
+		"192.0.2.9":        "192.0.2.9",
+		"::ffff:192.0.2.9": "192.0.2.9",
+		"::ffff:c000:209":  "192.0.2.9",
+	} {
+		d, err := NewDiscoverer(dest, nil, nil)
+		if err != nil {
+			t.Errorf("NewDiscoverer(%q) failed: %v", dest, err)
+			continue
+		}
+		if d.DestIP != want {
+			t.Errorf("NewDiscoverer(%q) has DestIP %q, want %q", dest, d.DestIP, want)
+		}
+	}
+	for _, dest := range []string{"2001:db8::1", "example.com", ""} {
+		if _, err := NewDiscoverer(dest, nil, nil); err == nil {
+			t.Errorf("NewDiscoverer(%q) succeeded, want an error", dest)
+		}
+	}
+}
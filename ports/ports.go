@@ -4,23 +4,33 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/a-tharva/ipmaster/ipinfo"
 	"github.com/rivo/tview"
 )
 
+// maxConcurrent bounds the number of connection attempts in flight
+const maxConcurrent = 256
+
 type PortScanner struct {
 	TargetIP   string
 	StartPort  int
 	EndPort    int
+	Ports      []int // Ports to scan instead of StartPort-EndPort, if set
 	Timeout    time.Duration
+	Source     ipinfo.Source // Interface or address to connect from
+	open       []int
 	resultView *tview.TextView
 	app        *tview.Application
 }
 
+// NewPortScanner creates a scanner for targetIP. app and resultView may be
+// nil to scan without a TUI; the result is then read with OpenPorts.
 func NewPortScanner(targetIP string, app *tview.Application, resultView *tview.TextView) (*PortScanner, error) {
 	if net.ParseIP(targetIP) == nil {
 		return nil, fmt.Errorf("invalid target IP: %s", targetIP)
@@ -35,23 +45,55 @@ func NewPortScanner(targetIP string, app *tview.Application, resultView *tview.T
 	}, nil
 }
 
+// OpenPorts returns the open ports found by ScanPorts, in ascending order
+func (ps *PortScanner) OpenPorts() []int {
+	return ps.open
+}
+
+// ScanList returns the ports ScanPorts will try
+func (ps *PortScanner) ScanList() []int {
+	if len(ps.Ports) > 0 {
+		return ps.Ports
+	}
+	var list []int
+	for port := ps.StartPort; port <= ps.EndPort; port++ {
+		list = append(list, port)
+	}
+	return list
+}
+
+// describe names the scanned ports for messages, e.g. "1-1024"
+func (ps *PortScanner) describe() string {
+	if len(ps.Ports) > 0 {
+		return fmt.Sprintf("%d ports", len(ps.Ports))
+	}
+	return fmt.Sprintf("%d-%d", ps.StartPort, ps.EndPort)
+}
+
 func (ps *PortScanner) ScanPorts() error {
-	ps.resultView.Clear()
-	ps.resultView.SetText(fmt.Sprintf("Scanning ports on %s (%d-%d)...\n", ps.TargetIP, ps.StartPort, ps.EndPort))
+	if err := ps.Source.Check(); err != nil {
+		ps.updateText(fmt.Sprintf("Port scan failed: %v", err))
+		return err
+	}
+	ps.updateText(fmt.Sprintf("Scanning ports on %s (%s)...\n", ps.TargetIP, ps.describe()))
 
-	var openPorts []string
+	var openPorts []int
 	var mu sync.Mutex
 	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrent)
+	dialer := ps.Source.Dialer("tcp", ps.Timeout)
 
-	for port := ps.StartPort; port <= ps.EndPort; port++ {
+	for _, port := range ps.ScanList() {
 		wg.Add(1)
+		sem <- struct{}{}
 		go func(p int) {
 			defer wg.Done()
-			addr := fmt.Sprintf("%s:%d", ps.TargetIP, p)
-			conn, err := net.DialTimeout("tcp", addr, ps.Timeout)
+			defer func() { <-sem }()
+			addr := net.JoinHostPort(ps.TargetIP, strconv.Itoa(p))
+			conn, err := dialer.Dial("tcp", addr)
 			if err == nil {
 				mu.Lock()
-				openPorts = append(openPorts, strconv.Itoa(p))
+				openPorts = append(openPorts, p)
 				mu.Unlock()
 				conn.Close()
 			}
@@ -59,17 +101,26 @@ func (ps *PortScanner) ScanPorts() error {
 	}
 
 	wg.Wait()
+	sort.Ints(openPorts)
+	ps.open = openPorts
 
 	if len(openPorts) == 0 {
-		ps.updateText(fmt.Sprintf("No open ports found on %s (%d-%d)", ps.TargetIP, ps.StartPort, ps.EndPort))
+		ps.updateText(fmt.Sprintf("No open ports found on %s (%s)", ps.TargetIP, ps.describe()))
 	} else {
-		ps.updateText(fmt.Sprintf("Open ports on %s: %s", ps.TargetIP, strings.Join(openPorts, ", ")))
+		var list []string
+		for _, p := range openPorts {
+			list = append(list, strconv.Itoa(p))
+		}
+		ps.updateText(fmt.Sprintf("Open ports on %s: %s", ps.TargetIP, strings.Join(list, ", ")))
 	}
 	log.Printf("Port scan completed for %s: %v", ps.TargetIP, openPorts)
 	return nil
 }
 
 func (ps *PortScanner) updateText(text string) {
+	if ps.app == nil {
+		return
+	}
 	ps.app.QueueUpdateDraw(func() {
 		ps.resultView.SetText(text)
 	})
@@ -0,0 +1,126 @@
+package resolve
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Family selects which address family a hostname resolves to
+type Family string
+
+const (
+	Any  Family = "ip"  // Prefer IPv4, fall back to IPv6
+	IPv4 Family = "ip4" // A records only
+	IPv6 Family = "ip6" // AAAA records only
+)
+
+// Families lists the selectable families in display order
+var Families = []Family{Any, IPv4, IPv6}
+
+func (f Family) String() string {
+	switch f {
+	case IPv4:
+		return "IPv4"
+	case IPv6:
+		return "IPv6"
+	}
+	return "Any"
+}
+
+// Timeout bounds a single lookup
+var Timeout = 5 * time.Second
+
+// lookupIP queries DNS, replaced in tests
+var lookupIP = net.DefaultResolver.LookupIP
+
+// Result holds the outcome of resolving a host
+type Result struct {
+	Host     string
+	Addr     net.IP        // Address selected for probing
+	Addrs    []net.IP      // Every address of the requested family
+	Duration time.Duration // Zero when Host was already an IP literal
+}
+
+// Lookup resolves host to an address of the requested family. IP literals
+// are returned without a DNS query.
+func Lookup(host string, family Family) (Result, error) {
+	res := Result{Host: host}
+	if ip := net.ParseIP(host); ip != nil {
+		if !matchesFamily(ip, family) {
+			return res, fmt.Errorf("%s is not an %s address", host, family)
+		}
+		res.Addr = ip
+		res.Addrs = []net.IP{ip}
+		return res, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	start := time.Now()
+	found, err := lookupIP(ctx, string(family), host)
+	res.Duration = time.Since(start)
+	if err != nil {
+		return res, fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	var ips []net.IP
+	for _, ip := range found {
+		if matchesFamily(ip, family) {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		if family == Any {
+			return res, fmt.Errorf("no addresses for %s", host)
+		}
+		return res, fmt.Errorf("no %s addresses for %s", family, host)
+	}
+
+	res.Addrs = ips
+	res.Addr = ips[0]
+	if family == Any {
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				res.Addr = ip
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+// ValidHost reports whether s is an IP address or a syntactically valid
+// hostname (RFC 1123 labels)
+func ValidHost(s string) bool {
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func matchesFamily(ip net.IP, family Family) bool {
+	switch family {
+	case IPv4:
+		return ip.To4() != nil
+	case IPv6:
+		return ip.To4() == nil
+	}
+	return true
+}
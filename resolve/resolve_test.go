@@ -0,0 +1,128 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestValidHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"example.com.", true},
+		{"a-b.example", true},
+		{"_dmarc.example.com", true},
+		{"localhost", true},
+		{"192.0.2.1", true},
+		{"2001:db8::1", true},
+		{"::ffff:192.0.2.1", true},
+		{"", false},
+		{".", false},
+		{"exa mple.com", false},
+		{"-example.com", false},
+		{"example-.com", false},
+		{"example..com", false},
+		{"exämple.com", false},
+		{"host:80", false},
+		{strings.Repeat("a", 64) + ".com", false},
+		{strings.Repeat("a", 63) + ".com", true},
+	}
+	for _, tt := range tests {
+		if got := ValidHost(tt.host); got != tt.want {
+			t.Errorf("ValidHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+// stubLookup makes Lookup answer every query with addrs, or with err
+func stubLookup(t *testing.T, addrs []string, err error) {
+	t.Helper()
+	orig := lookupIP
+	t.Cleanup(func() { lookupIP = orig })
+	lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+		var ips []net.IP
+		for _, addr := range addrs {
+			ips = append(ips, net.ParseIP(addr))
+		}
+		return ips, err
+	}
+}
+
+func TestLookupFamily(t *testing.T) {
+	mixed := []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"}
+	tests := []struct {
+		name     string
+		addrs    []string
+		family   Family
+		wantAddr string
+		wantAll  []string
+		wantErr  string
+	}{
+		{"any prefers ipv4", mixed, Any, "192.0.2.1", mixed, ""},
+		{"any falls back to ipv6", []string{"2001:db8::1"}, Any, "2001:db8::1", []string{"2001:db8::1"}, ""},
+		{"ipv4 only", mixed, IPv4, "192.0.2.1", []string{"192.0.2.1", "192.0.2.2"}, ""},
+		{"ipv6 only", mixed, IPv6, "2001:db8::1", []string{"2001:db8::1", "2001:db8::2"}, ""},
+		{"no ipv6", []string{"192.0.2.1"}, IPv6, "", nil, "no IPv6 addresses for example.com"},
+		{"no addresses", nil, Any, "", nil, "no addresses for example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubLookup(t, tt.addrs, nil)
+			res, err := Lookup("example.com", tt.family)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Lookup error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Lookup failed: %v", err)
+			}
+			var all []string
+			for _, ip := range res.Addrs {
+				all = append(all, ip.String())
+			}
+			if res.Addr.String() != tt.wantAddr || !slices.Equal(all, tt.wantAll) {
+				t.Errorf("Lookup = %s %v, want %s %v", res.Addr, all, tt.wantAddr, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestLookupLiteral(t *testing.T) {
+	stubLookup(t, nil, errors.New("unexpected DNS query"))
+	tests := []struct {
+		host    string
+		family  Family
+		wantErr bool
+	}{
+		{"192.0.2.1", Any, false},
+		{"192.0.2.1", IPv4, false},
+		{"192.0.2.1", IPv6, true},
+		{"2001:db8::1", IPv6, false},
+		{"2001:db8::1", IPv4, true},
+	}
+	for _, tt := range tests {
+		res, err := Lookup(tt.host, tt.family)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Lookup(%q, %s) error = %v, want error %v", tt.host, tt.family, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (res.Addr.String() != tt.host || res.Duration != 0) {
+			t.Errorf("Lookup(%q, %s) = %s after %s, want the literal without a query", tt.host, tt.family, res.Addr, res.Duration)
+		}
+	}
+}
+
+func TestLookupError(t *testing.T) {
+	stubLookup(t, nil, errors.New("server misbehaving"))
+	if _, err := Lookup("example.com", Any); err == nil {
+		t.Errorf("Lookup succeeded despite the resolver failing")
+	}
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// TargetReplay is the stored history of one target in a session
+type TargetReplay struct {
+	Target  string
+	Spec    ping.Spec
+	Session ping.Statistics // Over the whole session
+	Window  ping.Statistics // Over the last results, as shown when the session ended
+	Samples []ping.Sample
+	Outages ping.OutageSummary
+}
+
+// Replay is a stored session rebuilt from its records
+type Replay struct {
+	Start, End time.Time
+	Targets    []TargetReplay // In order of first appearance
+}
+
+// NewReplay rebuilds per-target statistics and history from records,
+// using window results for the windowed statistics. Follow-up records add
+// their anomalies without counting as probes.
+func NewReplay(records []Record, window int) Replay {
+	var r Replay
+	stats := make(map[string]*ping.Stats)
+	outages := make(map[string]*ping.Outages)
+	index := make(map[string]int)
+	for _, rec := range records {
+		res := rec.Result()
+		if rec.Followup {
+			if s, ok := stats[rec.Target]; ok {
+				s.AddAnomalies(res.Anomalies)
+			}
+			continue
+		}
+		i, ok := index[rec.Target]
+		if !ok {
+			i = len(r.Targets)
+			index[rec.Target] = i
+			stats[rec.Target] = ping.NewStats(window)
+			outages[rec.Target] = ping.NewOutages(1)
+			r.Targets = append(r.Targets, TargetReplay{Target: rec.Target, Spec: res.Spec})
+		}
+		stats[rec.Target].Add(res)
+		outages[rec.Target].Add(res)
+		r.Targets[i].Samples = append(r.Targets[i].Samples,
+			ping.Sample{Time: res.Time, RTT: res.RTT, Lost: res.Err != nil})
+
+		if r.Start.IsZero() || res.Time.Before(r.Start) {
+			r.Start = res.Time
+		}
+		if res.Time.After(r.End) {
+			r.End = res.Time
+		}
+	}
+	for i := range r.Targets {
+		s := stats[r.Targets[i].Target]
+		r.Targets[i].Session, r.Targets[i].Window = s.Session(), s.Window()
+		// An outage still open when the session ended lasted until its end
+		r.Targets[i].Outages = outages[r.Targets[i].Target].Summary(r.End)
+	}
+	return r
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-tharva/ipmaster/logging"
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// sessionLayout names session files after the time they were started, to
+// the microsecond so sessions started within the same second stay apart.
+// Files of older versions use oldSessionLayout.
+const (
+	sessionLayout    = "20060102-150405.000000"
+	oldSessionLayout = "20060102-150405"
+)
+
+// Record is one probe result as stored on disk, or with Followup set the
+// anomalies of an earlier probe noticed after its result was stored
+type Record struct {
+	Time   time.Time `json:"t"`
+	Target string    `json:"target"` // Target spec, see ping.ParseSpec
+	Addr   string    `json:"addr,omitempty"`
+	Seq    int       `json:"seq"`
+	RTT    float64   `json:"rtt_ms,omitempty"`
+	Err    string    `json:"err,omitempty"`
+	Dup    int       `json:"dup,omitempty"`   // Duplicate replies
+	Reord  int       `json:"reord,omitempty"` // Reordered replies
+	Late   int       `json:"late,omitempty"`  // Replies after the timeout
+	// Followup marks anomalies of probe Seq of Target rather than a probe
+	Followup bool `json:"followup,omitempty"`
+}
+
+// NewRecord converts a probe result for storage
+func NewRecord(res ping.Result) Record {
+	rec := Record{Time: res.Time, Target: res.Target, Addr: res.Addr, Seq: res.Seq,
+		Dup: res.Anomalies.Duplicates, Reord: res.Anomalies.Reordered, Late: res.Anomalies.Late}
+	if res.Err != nil {
+		rec.Err = res.Err.Error()
+	} else {
+		rec.RTT = res.RTT.Seconds() * 1000
+	}
+	return rec
+}
+
+// Result converts a stored record back into a probe result
+func (r Record) Result() ping.Result {
+	spec, _ := ping.ParseSpec(r.Target)
+	res := ping.Result{
+		Target:    r.Target,
+		Spec:      spec,
+		Addr:      r.Addr,
+		Seq:       r.Seq,
+		RTT:       time.Duration(r.RTT * float64(time.Millisecond)),
+		Time:      r.Time,
+		Anomalies: ping.Anomalies{Duplicates: r.Dup, Reordered: r.Reord, Late: r.Late},
+	}
+	switch {
+	case r.Err == ping.ErrTimeout.Error():
+		res.Err = ping.ErrTimeout
+	case r.Err != "":
+		res.Err = errors.New(r.Err)
+	}
+	return res
+}
+
+// Dir returns the directory holding session files, creating it if needed
+func Dir() (string, error) {
+	dir := filepath.Join(logging.GetDataDir(), "history")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Recorder appends the results of one ping session to a JSONL file. The
+// file is created on the first result so sessions without results leave
+// nothing behind. It is safe for concurrent use.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	enc  *json.Encoder
+	err  error
+}
+
+// lastStart is the start time of the newest session of this process
+var (
+	lastStartMu sync.Mutex
+	lastStart   time.Time
+)
+
+// NewRecorder prepares a session file in dir named after the current time
+func NewRecorder(dir string) *Recorder {
+	// Sessions of this process get distinct names even within a microsecond
+	lastStartMu.Lock()
+	start := time.Now().Truncate(time.Microsecond)
+	if !start.After(lastStart) {
+		start = lastStart.Add(time.Microsecond)
+	}
+	lastStart = start
+	lastStartMu.Unlock()
+
+	name := fmt.Sprintf("session-%s.jsonl", start.Format(sessionLayout))
+	return &Recorder{path: filepath.Join(dir, name)}
+}
+
+// Path returns the session file written by r
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record appends res to the session file. After the first write error the
+// recorder stops writing and returns that error.
+func (r *Recorder) Record(res ping.Result) error {
+	return r.write(NewRecord(res))
+}
+
+// RecordLate appends anomalies of probe seq of target noticed after its
+// result was recorded, as a follow-up record
+func (r *Recorder) RecordLate(target string, seq int, a ping.Anomalies) error {
+	return r.write(Record{Time: time.Now(), Target: target, Seq: seq,
+		Dup: a.Duplicates, Reord: a.Reordered, Late: a.Late, Followup: true})
+}
+
+func (r *Recorder) write(rec Record) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	if r.file == nil {
+		r.file, r.err = os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if r.err != nil {
+			return r.err
+		}
+		r.enc = json.NewEncoder(r.file)
+	}
+	r.err = r.enc.Encode(rec)
+	return r.err
+}
+
+// Close closes the session file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	if r.err == nil {
+		r.err = os.ErrClosed
+	}
+	return err
+}
+
+// Session describes a stored session file
+type Session struct {
+	Path  string
+	Start time.Time
+	Size  int64
+}
+
+// Sessions lists the session files in dir, newest first
+func Sessions(dir string) ([]Session, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(strings.TrimSuffix(name, ".jsonl"), "session-")
+		if !ok || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		start, err := time.ParseInLocation(sessionLayout, stamp, time.Local)
+		if err != nil {
+			start, err = time.ParseInLocation(oldSessionLayout, stamp, time.Local)
+		}
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, Session{Path: filepath.Join(dir, name), Start: start, Size: info.Size()})
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].Start.After(sessions[j].Start) })
+	return sessions, nil
+}
+
+// Prune deletes sessions started more than maxAge ago and all but the
+// newest maxSessions sessions. Zero disables either limit.
+func Prune(dir string, maxAge time.Duration, maxSessions int) error {
+	sessions, err := Sessions(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, s := range sessions {
+		tooOld := maxAge > 0 && time.Since(s.Start) > maxAge
+		tooMany := maxSessions > 0 && i >= maxSessions
+		if tooOld || tooMany {
+			if err := os.Remove(s.Path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Load reads every record of a session file. A truncated last line, as left
+// by a crash, is ignored.
+func Load(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			if !scanner.Scan() {
+				break // Partial final record
+			}
+			return records, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
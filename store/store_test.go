@@ -0,0 +1,96 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+func TestRecorderSessionsDistinct(t *testing.T) {
+	dir := t.TempDir()
+	first, second := NewRecorder(dir), NewRecorder(dir)
+	if first.Path() == second.Path() {
+		t.Fatalf("sessions started together share %s", first.Path())
+	}
+	for _, r := range []*Recorder{first, second} {
+		if err := r.Record(ping.Result{Target: "8.8.8.8", RTT: time.Millisecond, Time: time.Now()}); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+		r.Close()
+	}
+
+	sessions, err := Sessions(dir)
+	if err != nil {
+		t.Fatalf("Sessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions found %d sessions, want 2", len(sessions))
+	}
+	if sessions[0].Path != second.Path() {
+		t.Errorf("newest session is %s, want %s", sessions[0].Path, second.Path())
+	}
+	for _, s := range sessions {
+		records, err := Load(s.Path)
+		if err != nil || len(records) != 1 {
+			t.Errorf("Load(%s) = %d records, %v, want 1", s.Path, len(records), err)
+		}
+	}
+}
+
+func TestSessionsOldNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"session-20240102-030405.jsonl", "session-20240102-030405.123456.jsonl", "notes.txt", "session-bad.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sessions, err := Sessions(dir)
+	if err != nil {
+		t.Fatalf("Sessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Sessions found %v, want the two session files", sessions)
+	}
+	want := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.Local)
+	if !sessions[0].Start.Equal(want) || !sessions[1].Start.Equal(want.Truncate(time.Second)) {
+		t.Errorf("session starts %s and %s, want %s and %s", sessions[0].Start, sessions[1].Start, want, want.Truncate(time.Second))
+	}
+}
+
+func TestRecordLateReplay(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	start := time.Now()
+	results := []ping.Result{
+		{Target: "8.8.8.8", Seq: 0, Err: ping.ErrTimeout, Time: start},
+		{Target: "8.8.8.8", Seq: 1, RTT: time.Millisecond, Time: start.Add(time.Second)},
+	}
+	for _, res := range results {
+		if err := r.Record(res); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	if err := r.RecordLate("8.8.8.8", 0, ping.Anomalies{Reordered: 1, Late: 1}); err != nil {
+		t.Fatalf("RecordLate failed: %v", err)
+	}
+	r.Close()
+
+	records, err := Load(r.Path())
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	replay := NewReplay(records, ping.DefaultWindow)
+	if len(replay.Targets) != 1 {
+		t.Fatalf("replay has %d targets, want 1", len(replay.Targets))
+	}
+	target := replay.Targets[0]
+	if target.Session.Sent != 2 || len(target.Samples) != 2 {
+		t.Errorf("follow-up counted as a probe: %d sent, %d samples", target.Session.Sent, len(target.Samples))
+	}
+	want := ping.Anomalies{Reordered: 1, Late: 1}
+	if target.Session.Anomalies != want || target.Window.Anomalies != want {
+		t.Errorf("anomalies = %+v session, %+v window, want %+v", target.Session.Anomalies, target.Window.Anomalies, want)
+	}
+}
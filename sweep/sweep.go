@@ -0,0 +1,227 @@
+package sweep
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+// MaxHosts caps how many addresses a single sweep may expand to
+const MaxHosts = 65536
+
+// Host is the sweep outcome for one address
+type Host struct {
+	Addr string
+	Up   bool
+	RTT  time.Duration
+	Err  error
+}
+
+// Sweeper probes many addresses with bounded concurrency
+type Sweeper struct {
+	Concurrency int
+	Timeout     time.Duration
+	Privileged  bool
+}
+
+// NewSweeper creates a Sweeper using the detected ICMP mode
+func NewSweeper() *Sweeper {
+	return &Sweeper{
+		Concurrency: 64,
+		Timeout:     time.Second,
+		Privileged:  ping.DetectMode().Privileged,
+	}
+}
+
+// Expand turns a comma-separated list of addresses, CIDR blocks
+// (10.0.0.0/24) and ranges (10.0.0.10-10.0.0.50 or 10.0.0.10-50) into
+// individual addresses. Network and broadcast addresses of IPv4 blocks are
+// skipped.
+func Expand(spec string) ([]string, error) {
+	var hosts []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var expanded []string
+		var err error
+		switch {
+		case strings.Contains(part, "/"):
+			expanded, err = expandPrefix(part)
+		case strings.Contains(part, "-"):
+			expanded, err = expandRange(part)
+		default:
+			var addr netip.Addr
+			addr, err = netip.ParseAddr(part)
+			expanded = []string{addr.String()}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid sweep target %q: %w", part, err)
+		}
+
+		// Overlapping parts list an address once
+		for _, host := range expanded {
+			if !seen[host] {
+				seen[host] = true
+				hosts = append(hosts, host)
+			}
+		}
+		if len(hosts) > MaxHosts {
+			return nil, fmt.Errorf("sweep exceeds %d hosts", MaxHosts)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("no sweep targets given")
+	}
+	return hosts, nil
+}
+
+func expandPrefix(s string) ([]string, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	prefix = prefix.Masked()
+
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("prefix larger than %d hosts", MaxHosts)
+	}
+
+	var hosts []string
+	for addr := prefix.Addr(); prefix.Contains(addr); addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+	}
+	// Drop network and broadcast addresses, except for /31 and /32
+	if prefix.Addr().Is4() && hostBits > 1 {
+		hosts = hosts[1 : len(hosts)-1]
+	}
+	return hosts, nil
+}
+
+func expandRange(s string) ([]string, error) {
+	from, to, _ := strings.Cut(s, "-")
+	start, err := netip.ParseAddr(strings.TrimSpace(from))
+	if err != nil {
+		return nil, err
+	}
+
+	to = strings.TrimSpace(to)
+	end, err := netip.ParseAddr(to)
+	if err != nil && start.Is4() {
+		// Short form: 10.0.0.10-50 replaces the last octet
+		last, atoiErr := strconv.Atoi(to)
+		if atoiErr != nil || last < 0 || last > 255 {
+			return nil, fmt.Errorf("invalid range end %q", to)
+		}
+		octets := start.As4()
+		octets[3] = byte(last)
+		end, err = netip.AddrFrom4(octets), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if start.Is4() != end.Is4() {
+		return nil, fmt.Errorf("range mixes IPv4 and IPv6")
+	}
+	if end.Less(start) {
+		return nil, fmt.Errorf("range end %s before start %s", end, start)
+	}
+
+	var hosts []string
+	for addr := start; ; addr = addr.Next() {
+		hosts = append(hosts, addr.String())
+		if len(hosts) > MaxHosts {
+			return nil, fmt.Errorf("range larger than %d hosts", MaxHosts)
+		}
+		// Next wraps to the invalid zero Addr past the all-ones address
+		if addr == end {
+			break
+		}
+	}
+	return hosts, nil
+}
+
+// Run probes every address once, calling onResult as each finishes, and
+// returns the results in input order. Closing stop abandons hosts not yet
+// probed.
+func (s *Sweeper) Run(addrs []string, onResult func(Host), stop <-chan struct{}) []Host {
+	settings := ping.DefaultSettings()
+	settings.Timeout = s.Timeout
+
+	results := make([]Host, len(addrs))
+	sem := make(chan struct{}, max(s.Concurrency, 1))
+	var wg sync.WaitGroup
+
+	start := time.Now()
+loop:
+	for i, addr := range addrs {
+		select {
+		case sem <- struct{}{}:
+		case <-stop:
+			break loop
+		}
+
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			rtt, err := ping.Probe(addr, settings, s.Privileged)
+			host := Host{Addr: addr, Up: err == nil, RTT: rtt, Err: err}
+			results[i] = host
+			if onResult != nil {
+				onResult(host)
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	log.Printf("Sweep of %d hosts finished in %s", len(addrs), time.Since(start))
+	return results
+}
+
+// Alive returns the addresses of hosts that answered
+func Alive(hosts []Host) []string {
+	var alive []string
+	for _, host := range hosts {
+		if host.Up {
+			alive = append(alive, host.Addr)
+		}
+	}
+	return alive
+}
+
+// WriteCSV writes sweep results as CSV with an address,status,rtt_ms header
+func WriteCSV(w io.Writer, hosts []Host) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"address", "status", "rtt_ms"}); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if host.Addr == "" {
+			continue // Not probed, sweep was stopped
+		}
+		status, rtt := "down", ""
+		if host.Up {
+			status = "up"
+			rtt = fmt.Sprintf("%.3f", host.RTT.Seconds()*1000)
+		}
+		if err := cw.Write([]string{host.Addr, status, rtt}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
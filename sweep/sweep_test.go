@@ -0,0 +1,84 @@
+package sweep
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"10.0.0.1", []string{"10.0.0.1"}},
+		{"10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2"}},
+		{"10.0.0.5/30", []string{"10.0.0.5", "10.0.0.6"}},
+		{"10.0.0.0/31", []string{"10.0.0.0", "10.0.0.1"}},
+		{"10.0.0.7/32", []string{"10.0.0.7"}},
+		{"2001:db8::/126", []string{"2001:db8::", "2001:db8::1", "2001:db8::2", "2001:db8::3"}},
+		{"10.0.0.10-12", []string{"10.0.0.10", "10.0.0.11", "10.0.0.12"}},
+		{"10.0.0.254 - 10.0.1.1", []string{"10.0.0.254", "10.0.0.255", "10.0.1.0", "10.0.1.1"}},
+		{"2001:db8::1-2001:db8::2", []string{"2001:db8::1", "2001:db8::2"}},
+		// Ranges ending at the all-ones address stop there
+		{"255.255.255.253-255", []string{"255.255.255.253", "255.255.255.254", "255.255.255.255"}},
+		{"255.255.255.254 - 255.255.255.255", []string{"255.255.255.254", "255.255.255.255"}},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe-ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			[]string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"}},
+		{"255.255.255.252/30", []string{"255.255.255.253", "255.255.255.254"}},
+		{" 10.0.0.1 , ,10.0.0.3", []string{"10.0.0.1", "10.0.0.3"}},
+		// Overlapping parts keep the first occurrence of each address
+		{"10.0.0.1-3, 10.0.0.2, 10.0.0.0/30", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Expand(tt.spec)
+			if err != nil {
+				t.Fatalf("Expand(%q) failed: %v", tt.spec, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expand(%q) = %v, want %v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		" , ",
+		"10.0.0.300",
+		"example.com",
+		"10.0.0.10-50abc",
+		"10.0.0.10-256",
+		"10.0.0.10-",
+		"10.0.0.10-5",
+		"10.0.0.10-10.0.0.1",
+		"10.0.0.1-2001:db8::1",
+		"2001:db8::1-5",
+		"10.0.0.0/33",
+		"10.0.0.0/8",
+		"10.0.0.0-10.1.0.1",
+	} {
+		if got, err := Expand(spec); err == nil {
+			t.Errorf("Expand(%q) = %v, want an error", spec, got)
+		}
+	}
+}
+
+// TestRunZeroConcurrency checks that a zero-valued Sweeper probes one host
+// at a time rather than blocking forever
+func TestRunZeroConcurrency(t *testing.T) {
+	s := &Sweeper{Timeout: 100 * time.Millisecond}
+	done := make(chan []Host)
+	go func() { done <- s.Run([]string{"127.0.0.1", "127.0.0.2"}, nil, nil) }()
+
+	select {
+	case hosts := <-done:
+		if len(hosts) != 2 || hosts[0].Addr != "127.0.0.1" || hosts[1].Addr != "127.0.0.2" {
+			t.Errorf("Run = %+v, want a result per address", hosts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run with zero concurrency did not finish")
+	}
+}
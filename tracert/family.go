@@ -0,0 +1,138 @@
+package tracert
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// family holds what differs between ICMP and ICMPv6 traceroutes
+type family struct {
+	ipv6         bool
+	version      string // "4" or "6", the suffix of network names such as "udp4"
+	network      string // Raw ICMP socket network for net.ListenPacket
+	proto        int    // Protocol number for icmp.ParseMessage
+	echo         icmp.Type
+	echoReply    icmp.Type
+	timeExceeded icmp.Type
+	unreachable  icmp.Type
+}
+
+var (
+	familyV4 = family{
+		version:      "4",
+		network:      "ip4:icmp",
+		proto:        1,
+		echo:         ipv4.ICMPTypeEcho,
+		echoReply:    ipv4.ICMPTypeEchoReply,
+		timeExceeded: ipv4.ICMPTypeTimeExceeded,
+		unreachable:  ipv4.ICMPTypeDestinationUnreachable,
+	}
+	familyV6 = family{
+		ipv6:         true,
+		version:      "6",
+		network:      "ip6:ipv6-icmp",
+		proto:        58,
+		echo:         ipv6.ICMPTypeEchoRequest,
+		echoReply:    ipv6.ICMPTypeEchoReply,
+		timeExceeded: ipv6.ICMPTypeTimeExceeded,
+		unreachable:  ipv6.ICMPTypeDestinationUnreachable,
+	}
+)
+
+// familyOf returns the family of ip, an IPv4 or IPv6 address
+func familyOf(ip string) family {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return familyV6
+	}
+	return familyV4
+}
+
+// number returns the numeric value of an ICMP or ICMPv6 type
+func number(t icmp.Type) int {
+	switch t := t.(type) {
+	case ipv4.ICMPType:
+		return int(t)
+	case ipv6.ICMPType:
+		return int(t)
+	}
+	return -1
+}
+
+// unreachableText abbreviates a Destination Unreachable code like classic
+// traceroute, e.g. "!H" for host unreachable. Port Unreachable is how a
+// destination answers UDP probes, so it is not flagged.
+func (f family) unreachableText(code int) string {
+	codes := map[int]string{0: "!N", 1: "!H", 2: "!P", 3: "", 4: "!F", 9: "!X", 10: "!X", 13: "!X"}
+	if f.ipv6 {
+		codes = map[int]string{0: "!N", 1: "!X", 3: "!H", 4: "", 5: "!S", 6: "!X"}
+	}
+	if text, ok := codes[code]; ok {
+		return text
+	}
+	return fmt.Sprintf("!<%d>", code)
+}
+
+// packetConn sets the TTL or hop limit and the DSCP of the probes sent on conn
+type packetConn interface {
+	setHops(hops int) error
+	setDSCP(dscp int) error
+}
+
+type packetConnV4 struct{ *ipv4.PacketConn }
+
+func (c packetConnV4) setHops(hops int) error { return c.SetTTL(hops) }
+func (c packetConnV4) setDSCP(dscp int) error { return c.SetTOS(dscp << 2) }
+
+type packetConnV6 struct{ *ipv6.PacketConn }
+
+func (c packetConnV6) setHops(hops int) error { return c.SetHopLimit(hops) }
+func (c packetConnV6) setDSCP(dscp int) error { return c.SetTrafficClass(dscp << 2) }
+
+// packetConn wraps conn, a socket of the family
+func (f family) packetConn(conn net.PacketConn) packetConn {
+	if f.ipv6 {
+		return packetConnV6{ipv6.NewPacketConn(conn)}
+	}
+	return packetConnV4{ipv4.NewPacketConn(conn)}
+}
+
+// filterICMP makes conn, a raw ICMPv6 socket, only get the message types a
+// traceroute waits for, sparing it neighbor discovery and the like
+func (f family) filterICMP(conn net.PacketConn) error {
+	if !f.ipv6 {
+		return nil
+	}
+	var filter ipv6.ICMPFilter
+	filter.SetAll(true)
+	filter.Accept(ipv6.ICMPTypeEchoReply)
+	filter.Accept(ipv6.ICMPTypeTimeExceeded)
+	filter.Accept(ipv6.ICMPTypeDestinationUnreachable)
+	return ipv6.NewPacketConn(conn).SetICMPFilter(&filter)
+}
+
+// quoted returns the first 8 bytes of the transport header of data, the
+// start of a packet quoted in an ICMP error, or nil unless the packet is of
+// protocol proto and was sent to dest
+func (f family) quoted(data []byte, dest net.IP, proto int) []byte {
+	var hdrLen, quotedProto int
+	var dst net.IP
+	if f.ipv6 {
+		if len(data) < ipv6.HeaderLen {
+			return nil
+		}
+		hdrLen, quotedProto, dst = ipv6.HeaderLen, int(data[6]), net.IP(data[24:40])
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return nil
+		}
+		hdrLen, quotedProto, dst = int(data[0]&0x0f)*4, int(data[9]), net.IP(data[16:20])
+	}
+	if len(data) < hdrLen+8 || quotedProto != proto || !dst.Equal(dest) {
+		return nil
+	}
+	return data[hdrLen : hdrLen+8]
+}
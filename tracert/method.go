@@ -0,0 +1,218 @@
+package tracert
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/icmp"
+)
+
+// Method selects the kind of probe a traceroute sends
+type Method string
+
+const (
+	MethodICMP Method = "icmp" // ICMP echo requests
+	MethodUDP  Method = "udp"  // UDP datagrams to unused ports, answered by Port Unreachable
+	MethodTCP  Method = "tcp"  // TCP SYNs, answered by SYN-ACK or RST, for paths that drop ICMP echo
+)
+
+// Methods lists the selectable methods in display order
+var Methods = []Method{MethodICMP, MethodUDP, MethodTCP}
+
+func (m Method) String() string {
+	switch m {
+	case MethodUDP:
+		return "UDP"
+	case MethodTCP:
+		return "TCP SYN"
+	}
+	return "ICMP"
+}
+
+// ParseMethod reads a method name such as "udp"
+func ParseMethod(s string) (Method, error) {
+	switch strings.ToLower(s) {
+	case "", "icmp":
+		return MethodICMP, nil
+	case "udp":
+		return MethodUDP, nil
+	case "tcp", "syn":
+		return MethodTCP, nil
+	}
+	return MethodICMP, fmt.Errorf("invalid method %q, expected icmp, udp or tcp", s)
+}
+
+const (
+	protoTCP = 6
+	protoUDP = 17
+
+	defaultUDPPort = 33434
+	defaultTCPPort = 80
+
+	tcpSYN = 0x02
+	tcpRST = 0x04
+	tcpACK = 0x10
+)
+
+// port returns the UDP base port or TCP destination port of the probes
+func (t *Tracer) port() int {
+	switch {
+	case t.Port > 0:
+		return t.Port
+	case t.Method == MethodTCP:
+		return defaultTCPPort
+	}
+	return defaultUDPPort
+}
+
+// udpPort returns the destination port of UDP probe seq. Classic traceroute
+// moves to the next port with every probe; Paris mode keeps the flow.
+func (t *trace) udpPort(seq int) int {
+	if t.Paris {
+		return t.port()
+	}
+	return 1 + (t.port()+seq-2)%65535
+}
+
+// udpPayload returns the payload length of UDP probe seq. The length is
+// quoted in ICMP errors, so it tells probes of the same flow apart.
+func udpPayload(seq int) int {
+	return seq % 1024
+}
+
+// tcpSourcePort returns the source port of TCP probe seq
+func (t *trace) tcpSourcePort(seq int) int {
+	if t.Paris {
+		return t.localPort
+	}
+	return t.localPort + seq%1024
+}
+
+// tcpSeq returns the sequence number of TCP probe seq, which identifies it
+// in ICMP errors and in the acknowledgement of the answer
+func (t *trace) tcpSeq(seq int) uint32 {
+	return uint32(t.id)<<16 | uint32(seq&0xffff)
+}
+
+// send sends probe seq with the method of the trace
+func (t *trace) send(seq int) error {
+	switch t.Method {
+	case MethodUDP:
+		_, err := t.sendConn.WriteTo(make([]byte, udpPayload(seq)), &net.UDPAddr{IP: t.dest, Port: t.udpPort(seq)})
+		return err
+	case MethodTCP:
+		_, err := t.sendConn.WriteTo(t.synSegment(seq), &net.IPAddr{IP: t.dest})
+		return err
+	}
+
+	data := []byte("IPmaster")
+	if t.Paris {
+		// Some load balancers hash the ICMP checksum, so offset the change of
+		// the sequence number to keep it constant
+		fill := 0xffff - seq&0xffff
+		data = append(data, byte(fill>>8), byte(fill))
+	}
+	msg := icmp.Message{
+		Type: t.family.echo, Code: 0,
+		Body: &icmp.Echo{
+			ID:   t.id,
+			Seq:  seq,
+			Data: data,
+		},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = t.sendConn.WriteTo(b, &net.IPAddr{IP: t.dest})
+	return err
+}
+
+// matchesQuoted reports whether data, the start of a packet quoted in an
+// ICMP error, is probe seq
+func (t *trace) matchesQuoted(data []byte, seq int) bool {
+	proto := t.family.proto
+	switch t.Method {
+	case MethodUDP:
+		proto = protoUDP
+	case MethodTCP:
+		proto = protoTCP
+	}
+	hdr := t.family.quoted(data, t.dest, proto)
+	if hdr == nil {
+		return false
+	}
+	first, second := int(binary.BigEndian.Uint16(hdr[0:])), int(binary.BigEndian.Uint16(hdr[2:]))
+	third, fourth := int(binary.BigEndian.Uint16(hdr[4:])), int(binary.BigEndian.Uint16(hdr[6:]))
+
+	switch t.Method {
+	case MethodUDP:
+		return first == t.localPort && second == t.udpPort(seq) && third == 8+udpPayload(seq)
+	case MethodTCP:
+		return first == t.tcpSourcePort(seq) && second == t.port() && binary.BigEndian.Uint32(hdr[4:]) == t.tcpSeq(seq)
+	}
+	return hdr[0] == byte(number(t.family.echo)) && third == t.id && fourth == seq&0xffff
+}
+
+// tcpAnswer returns "syn-ack" or "rst" if segment, read from the raw TCP
+// socket, is the destination's answer to probe seq, and "" otherwise
+func (t *trace) tcpAnswer(segment []byte, from net.IP, seq int) string {
+	if len(segment) < 20 || !from.Equal(t.dest) {
+		return ""
+	}
+	src, dst := int(binary.BigEndian.Uint16(segment[0:])), int(binary.BigEndian.Uint16(segment[2:]))
+	ack, flags := binary.BigEndian.Uint32(segment[8:]), segment[13]
+	if src != t.port() || dst != t.tcpSourcePort(seq) || ack != t.tcpSeq(seq)+1 {
+		return ""
+	}
+	switch {
+	case flags&(tcpSYN|tcpACK) == tcpSYN|tcpACK:
+		return "syn-ack"
+	case flags&tcpRST != 0:
+		return "rst"
+	}
+	return ""
+}
+
+// synSegment builds TCP probe seq, a SYN with an MSS option
+func (t *trace) synSegment(seq int) []byte {
+	b := make([]byte, 24)
+	binary.BigEndian.PutUint16(b[0:], uint16(t.tcpSourcePort(seq)))
+	binary.BigEndian.PutUint16(b[2:], uint16(t.port()))
+	binary.BigEndian.PutUint32(b[4:], t.tcpSeq(seq))
+	b[12] = 6 << 4 // Header length in 32-bit words
+	b[13] = tcpSYN
+	binary.BigEndian.PutUint16(b[14:], 64240)
+	copy(b[20:], []byte{2, 4, 0x05, 0xb4}) // MSS 1460
+
+	// The checksum covers a pseudo header of the addresses, protocol and length
+	var pseudo []byte
+	if t.family.ipv6 {
+		pseudo = append(append(pseudo, t.local.To16()...), t.dest.To16()...)
+		pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(b)))
+		pseudo = append(pseudo, 0, 0, 0, protoTCP)
+	} else {
+		pseudo = append(append(pseudo, t.local.To4()...), t.dest.To4()...)
+		pseudo = append(pseudo, 0, protoTCP)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(b)))
+	}
+	binary.BigEndian.PutUint16(b[16:], checksum(append(pseudo, b...)))
+	return b
+}
+
+// checksum computes the Internet checksum of b
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,243 @@
+package tracert
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// testTrace returns a trace to dest as open would set it up, without sockets
+func testTrace(dest string, method Method, paris bool) *trace {
+	t, err := NewTracer(dest)
+	if err != nil {
+		panic(err)
+	}
+	t.Method, t.Paris, t.id = method, paris, 0x1234
+	local := "192.0.2.1"
+	if t.family.ipv6 {
+		local = "2001:db8::1"
+	}
+	return &trace{Tracer: t, dest: net.ParseIP(dest), local: net.ParseIP(local), localPort: 40000}
+}
+
+// ipPacket returns an IPv4 or IPv6 packet from the trace's local address to
+// dest carrying transport, a header of protocol proto
+func ipPacket(tr *trace, dest string, proto int, transport []byte) []byte {
+	if tr.family.ipv6 {
+		b := make([]byte, ipv6.HeaderLen)
+		b[0], b[6], b[7] = 6<<4, byte(proto), 64
+		binary.BigEndian.PutUint16(b[4:], uint16(len(transport)))
+		copy(b[8:], tr.local.To16())
+		copy(b[24:], net.ParseIP(dest).To16())
+		return append(b, transport...)
+	}
+	b := make([]byte, ipv4.HeaderLen)
+	b[0], b[8], b[9] = 4<<4|5, 1, byte(proto)
+	binary.BigEndian.PutUint16(b[2:], uint16(len(b)+len(transport)))
+	copy(b[12:], tr.local.To4())
+	copy(b[16:], net.ParseIP(dest).To4())
+	return append(b, transport...)
+}
+
+// udpProbe returns the UDP header of probe seq
+func udpProbe(tr *trace, seq int) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint16(b[0:], uint16(tr.localPort))
+	binary.BigEndian.PutUint16(b[2:], uint16(tr.udpPort(seq)))
+	binary.BigEndian.PutUint16(b[4:], uint16(8+udpPayload(seq)))
+	return b
+}
+
+// echoProbe returns the ICMP echo request of probe seq with identifier id
+func echoProbe(tr *trace, id, seq int) []byte {
+	msg := icmp.Message{Type: tr.family.echo, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("IPmaster")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// timeExceeded wraps quoted in an ICMP Time Exceeded message and returns
+// the quoted data as parsed back from the wire
+func timeExceeded(t *testing.T, tr *trace, quoted []byte) []byte {
+	msg := icmp.Message{Type: tr.family.timeExceeded, Body: &icmp.TimeExceeded{Data: quoted}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	parsed, err := icmp.ParseMessage(tr.family.proto, b)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return parsed.Body.(*icmp.TimeExceeded).Data
+}
+
+func TestMatchesQuoted(t *testing.T) {
+	const seq = 7
+	tests := []struct {
+		name   string
+		dest   string
+		method Method
+		paris  bool
+		quoted func(tr *trace) []byte
+		want   bool
+	}{
+		{"udp", "198.51.100.9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, udpProbe(tr, seq))
+		}, true},
+		{"udp other probe", "198.51.100.9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, udpProbe(tr, seq+1))
+		}, false},
+		{"udp paris", "198.51.100.9", MethodUDP, true, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, udpProbe(tr, seq))
+		}, true},
+		{"udp paris other probe", "198.51.100.9", MethodUDP, true, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, udpProbe(tr, seq+1))
+		}, false},
+		{"udp other destination", "198.51.100.9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.10", protoUDP, udpProbe(tr, seq))
+		}, false},
+		{"udp quoting tcp", "198.51.100.9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoTCP, udpProbe(tr, seq))
+		}, false},
+		{"udp truncated", "198.51.100.9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, udpProbe(tr, seq))[:ipv4.HeaderLen+4]
+		}, false},
+		{"udp v6", "2001:db8::9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::9", protoUDP, udpProbe(tr, seq))
+		}, true},
+		{"udp v6 other probe", "2001:db8::9", MethodUDP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::9", protoUDP, udpProbe(tr, seq-1))
+		}, false},
+		{"tcp", "198.51.100.9", MethodTCP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoTCP, tr.synSegment(seq))
+		}, true},
+		{"tcp other probe", "198.51.100.9", MethodTCP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoTCP, tr.synSegment(seq+1))
+		}, false},
+		{"tcp paris other probe", "198.51.100.9", MethodTCP, true, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoTCP, tr.synSegment(seq+1))
+		}, false},
+		{"tcp v6", "2001:db8::9", MethodTCP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::9", protoTCP, tr.synSegment(seq))
+		}, true},
+		{"tcp v6 other destination", "2001:db8::9", MethodTCP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::a", protoTCP, tr.synSegment(seq))
+		}, false},
+		{"icmp", "198.51.100.9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", 1, echoProbe(tr, tr.id, seq))
+		}, true},
+		{"icmp other probe", "198.51.100.9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", 1, echoProbe(tr, tr.id, seq+1))
+		}, false},
+		{"icmp other process", "198.51.100.9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", 1, echoProbe(tr, tr.id+1, seq))
+		}, false},
+		{"icmp quoting udp", "198.51.100.9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "198.51.100.9", protoUDP, echoProbe(tr, tr.id, seq))
+		}, false},
+		{"icmp v6", "2001:db8::9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::9", 58, echoProbe(tr, tr.id, seq))
+		}, true},
+		{"icmp v6 other probe", "2001:db8::9", MethodICMP, false, func(tr *trace) []byte {
+			return ipPacket(tr, "2001:db8::9", 58, echoProbe(tr, tr.id, seq+1))
+		}, false},
+		{"icmp v6 quoting v4 echo", "2001:db8::9", MethodICMP, false, func(tr *trace) []byte {
+			v4 := testTrace("198.51.100.9", MethodICMP, false)
+			return ipPacket(tr, "2001:db8::9", 58, echoProbe(v4, tr.id, seq))
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := testTrace(tt.dest, tt.method, tt.paris)
+			data := timeExceeded(t, tr, tt.quoted(tr))
+			if got := tr.matchesQuoted(data, seq); got != tt.want {
+				t.Errorf("matchesQuoted = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTCPAnswer(t *testing.T) {
+	const seq = 3
+	tr := testTrace("198.51.100.9", MethodTCP, false)
+	// answer returns the destination's reply to a SYN with the given
+	// ports, acknowledgement and flags
+	answer := func(src, dst int, ack uint32, flags byte) []byte {
+		b := make([]byte, 20)
+		binary.BigEndian.PutUint16(b[0:], uint16(src))
+		binary.BigEndian.PutUint16(b[2:], uint16(dst))
+		binary.BigEndian.PutUint32(b[8:], ack)
+		b[12], b[13] = 5<<4, flags
+		return b
+	}
+	port, srcPort, ack := tr.port(), tr.tcpSourcePort(seq), tr.tcpSeq(seq)+1
+
+	tests := []struct {
+		name    string
+		segment []byte
+		from    string
+		want    string
+	}{
+		{"syn-ack", answer(port, srcPort, ack, tcpSYN|tcpACK), "198.51.100.9", "syn-ack"},
+		{"rst", answer(port, srcPort, ack, tcpRST|tcpACK), "198.51.100.9", "rst"},
+		{"ack only", answer(port, srcPort, ack, tcpACK), "198.51.100.9", ""},
+		{"other host", answer(port, srcPort, ack, tcpSYN|tcpACK), "198.51.100.10", ""},
+		{"other probe", answer(port, tr.tcpSourcePort(seq+1), tr.tcpSeq(seq+1)+1, tcpSYN|tcpACK), "198.51.100.9", ""},
+		{"wrong ack", answer(port, srcPort, ack+1, tcpSYN|tcpACK), "198.51.100.9", ""},
+		{"other port", answer(port+1, srcPort, ack, tcpRST), "198.51.100.9", ""},
+		{"truncated", answer(port, srcPort, ack, tcpSYN|tcpACK)[:19], "198.51.100.9", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tr.tcpAnswer(tt.segment, net.ParseIP(tt.from), seq); got != tt.want {
+				t.Errorf("tcpAnswer = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want uint16
+	}{
+		{"empty", nil, 0xffff},
+		{"rfc 1071", []byte{0x00, 0x01, 0xf2, 0x03, 0xf4, 0xf5, 0xf6, 0xf7}, 0x220d},
+		{"odd length", []byte{0x01}, 0xfeff},
+		{"carry", []byte{0xff, 0xff, 0x00, 0x01}, 0xfffe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checksum(tt.data); got != tt.want {
+				t.Errorf("checksum = %#04x, want %#04x", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSynSegmentChecksum checks that the SYN checksum verifies against the
+// pseudo header, i.e. that summing it in gives zero
+func TestSynSegmentChecksum(t *testing.T) {
+	for _, dest := range []string{"198.51.100.9", "2001:db8::9"} {
+		tr := testTrace(dest, MethodTCP, false)
+		segment := tr.synSegment(5)
+		var pseudo []byte
+		if tr.family.ipv6 {
+			pseudo = append(append(pseudo, tr.local.To16()...), tr.dest.To16()...)
+			pseudo = append(pseudo, 0, 0, 0, byte(len(segment)), 0, 0, 0, protoTCP)
+		} else {
+			pseudo = append(append(pseudo, tr.local.To4()...), tr.dest.To4()...)
+			pseudo = append(pseudo, 0, protoTCP, 0, byte(len(segment)))
+		}
+		if sum := checksum(append(pseudo, segment...)); sum != 0 {
+			t.Errorf("%s: SYN checksum does not verify, got %#04x", dest, sum)
+		}
+	}
+}
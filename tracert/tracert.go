@@ -2,19 +2,21 @@ package tracert
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/rivo/tview"
+	"github.com/a-tharva/ipmaster/ipinfo"
 	"golang.org/x/net/icmp"
-	"golang.org/x/net/ipv4"
 )
 
 // IPInfo holds geolocation data from ipinfo.io
@@ -26,39 +28,62 @@ type IPInfo struct {
 
 // Tracer holds the configuration for a traceroute operation
 type Tracer struct {
-	DestIP      string
-	Privileged  bool // Only affects non-Windows OSes
-	MaxHops     int
-	Timeout     time.Duration
-	Probes      int // Number of probes per TTL (non-Windows only)
-	resultView  *tview.TextView
-	app         *tview.Application
-	traceText   strings.Builder
-	hops        []Hop
+	DestIP     string
+	Privileged bool // Only affects non-Windows OSes
+	MaxHops    int
+	Timeout    time.Duration
+	Probes     int           // Number of probes per TTL, each waiting up to Timeout (non-Windows only)
+	Locate     bool          // Look up the location of each hop on ipinfo.io
+	Source     ipinfo.Source // Interface or address to send from (non-Windows only)
+	DSCP       int           // Differentiated services codepoint of the probes (non-Windows only)
+	Method     Method        // Kind of probe, ICMP echo unless set (non-Windows only)
+	Port       int           // UDP base port or TCP port, 33434 or 80 if 0
+	Paris      bool          // Keep the flow of all probes the same, so ECMP routers send them one way (non-Windows only)
+	id         int           // Echo identifier of the probes, to tell our replies apart
+	family     family        // ICMP or ICMPv6, from DestIP
+	hops       []Hop
 }
 
 // Hop represents a single hop in the traceroute
 type Hop struct {
-	TTL      int
-	IP       string
-	RTT      float64
-	Location string
-	Timeout  bool
+	TTL      int      `json:"ttl"`
+	IP       string   `json:"ip"`         // First responder, "*" if no probe was answered
+	IPs      []string `json:"responders"` // Every responder, more than one on load-balanced (ECMP) paths
+	RTT      float64  `json:"rtt_ms"`     // Average RTT of the answered probes
+	Type     int      `json:"icmp_type"`
+	Code     int      `json:"icmp_code"`
+	Location string   `json:"location,omitempty"` // Location of the first responder
+	Timeout  bool     `json:"timeout"`            // No probe was answered
+	Replies  []Reply  `json:"probes"`             // One entry per probe, in the order they were sent
+}
+
+// Reply is the answer to one probe sent with the TTL of a hop
+type Reply struct {
+	IP      string  `json:"ip,omitempty"`
+	RTT     float64 `json:"rtt_ms"`
+	Type    int     `json:"icmp_type"`     // -1 for TCP answers
+	Code    int     `json:"icmp_code"`     // -1 for TCP answers
+	TCP     string  `json:"tcp,omitempty"` // "syn-ack" or "rst" when the destination answered a TCP probe
+	Timeout bool    `json:"timeout"`
 }
 
 // NewTracer creates a new Tracer instance
-func NewTracer(destIP string, app *tview.Application, resultView *tview.TextView) (*Tracer, error) {
-	if net.ParseIP(destIP) == nil {
+func NewTracer(destIP string) (*Tracer, error) {
+	ip := net.ParseIP(destIP)
+	if ip == nil {
 		return nil, fmt.Errorf("invalid destination IP: %s", destIP)
 	}
+	// Canonical text such as "2001:db8::1" is what replies are reported from
+	destIP = ip.String()
 	return &Tracer{
 		DestIP:     destIP,
 		Privileged: true,
 		MaxHops:    30,
 		Timeout:    5 * time.Second,
 		Probes:     3,
-		app:        app,
-		resultView: resultView,
+		Locate:     true,
+		id:         os.Getpid() & 0xffff,
+		family:     familyOf(destIP),
 	}, nil
 }
 
@@ -67,43 +92,125 @@ func (t *Tracer) SetPrivileged(privileged bool) {
 	t.Privileged = privileged
 }
 
-// Run executes the traceroute and updates the TUI
-func (t *Tracer) Run() error {
-	t.resultView.Clear()
-	t.resultView.SetText(fmt.Sprintf("Traceroute to %s...\n", t.DestIP))
-	t.traceText.Reset()
-	t.traceText.WriteString(fmt.Sprintf("Traceroute to %s:\n", t.DestIP))
-	t.traceText.WriteString("--------------------------------------------------\n")
+// Hops returns the hops found by Run
+func (t *Tracer) Hops() []Hop {
+	return t.hops
+}
+
+// Reached reports whether the last hop found by Run is the destination
+func (t *Tracer) Reached() bool {
+	return len(t.hops) > 0 && sameIP(t.hops[len(t.hops)-1].IP, t.DestIP)
+}
+
+// sameIP reports whether a and b are the same address, however written
+func sameIP(a, b string) bool {
+	ip := net.ParseIP(a)
+	return ip != nil && ip.Equal(net.ParseIP(b))
+}
+
+// Run executes the traceroute, calling onHop, if not nil, with every hop as
+// soon as it is known. It stops early and returns ctx.Err() when ctx is done.
+func (t *Tracer) Run(ctx context.Context, onHop func(Hop)) error {
+	t.hops = nil
+	emit := func(hop Hop) {
+		hop.summarize()
+		if t.Locate && !hop.Timeout {
+			hop.Location = location(ctx, hop.IP)
+		}
+		t.hops = append(t.hops, hop)
+		if onHop != nil {
+			onHop(hop)
+		}
+	}
 
+	var err error
 	if runtime.GOOS == "windows" {
-		return t.runWindows()
+		err = t.runWindows(ctx, emit)
+	} else {
+		err = t.runNonWindows(ctx, emit)
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// summarize fills the hop's responder, RTT, type and code from its replies
+func (h *Hop) summarize() {
+	h.IP, h.Timeout = "*", true
+	var total float64
+	answered := 0
+	for _, r := range h.Replies {
+		if r.Timeout {
+			continue
+		}
+		if answered == 0 {
+			h.IP, h.Type, h.Code, h.Timeout = r.IP, r.Type, r.Code, false
+		}
+		if !slices.Contains(h.IPs, r.IP) {
+			h.IPs = append(h.IPs, r.IP)
+		}
+		total += r.RTT
+		answered++
+	}
+	if answered > 0 {
+		h.RTT = total / float64(answered)
+	}
+}
+
+// ProbesText lists the RTT of every probe like classic traceroute, e.g.
+// "1.21 ms  *  10.0.0.9 1.35 ms", naming the responder whenever it differs
+// from the one before
+func (h Hop) ProbesText() string {
+	var parts []string
+	last := h.IP
+	f := familyOf(h.IP)
+	for _, r := range h.Replies {
+		switch {
+		case r.Timeout:
+			parts = append(parts, "*")
+		case r.IP != last:
+			parts = append(parts, fmt.Sprintf("%s %.2f ms", r.IP, r.RTT))
+			last = r.IP
+		default:
+			parts = append(parts, fmt.Sprintf("%.2f ms", r.RTT))
+		}
+		switch {
+		case r.TCP == "syn-ack":
+			parts[len(parts)-1] += " [open]"
+		case r.TCP == "rst":
+			parts[len(parts)-1] += " [closed]"
+		case !r.Timeout && r.Type == number(f.unreachable):
+			if text := f.unreachableText(r.Code); text != "" {
+				parts[len(parts)-1] += " " + text
+			}
+		}
 	}
-	return t.runNonWindows()
+	return strings.Join(parts, "  ")
 }
 
 // runWindows performs a traceroute using native tracert on Windows
-func (t *Tracer) runWindows() error {
-	cmd := exec.Command("tracert", "-d", t.DestIP) // -d avoids DNS lookups
+func (t *Tracer) runWindows(ctx context.Context, emit func(Hop)) error {
+	if !t.Source.IsZero() || t.DSCP != 0 {
+		return fmt.Errorf("choosing a source or DSCP is not supported by tracert on Windows")
+	}
+	if (t.Method != "" && t.Method != MethodICMP) || t.Paris {
+		return fmt.Errorf("tracert on Windows only sends ICMP probes")
+	}
+	cmd := exec.CommandContext(ctx, "tracert", "-d", "-h", fmt.Sprint(t.MaxHops), t.DestIP) // -d avoids DNS lookups
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		t.updateText(fmt.Sprintf("Failed to get stdout pipe: %v", err))
-		return err
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 	if err := cmd.Start(); err != nil {
-		t.updateText(fmt.Sprintf("Failed to start tracert: %v", err))
-		return err
+		return fmt.Errorf("failed to start tracert: %w", err)
 	}
 
 	scanner := bufio.NewScanner(stdout)
 	for scanner.Scan() {
-		line := scanner.Text()
-		hop, err := parseTracertLine(line)
+		hop, err := parseTracertLine(scanner.Text(), t.DestIP)
 		if err == nil {
-			location, err := getIPLocation(hop.IP)
-			if err != nil {
-				log.Printf("Location fetch error for %s: %v", hop.IP, err)
-			}
-			t.addHop(hop.TTL, hop.IP, fmt.Sprintf("%.2f ms", hop.RTT), hop.Timeout, location)
+			emit(hop)
 		}
 	}
 
@@ -113,12 +220,12 @@ func (t *Tracer) runWindows() error {
 	if err := cmd.Wait(); err != nil {
 		log.Printf("Tracert command failed: %v", err)
 	}
-	t.updateText(t.traceText.String())
 	return nil
 }
 
-// parseTracertLine parses a line from tracert output
-func parseTracertLine(line string) (Hop, error) {
+// parseTracertLine parses a hop line from tracert output, such as
+// "  2    12 ms    <1 ms    11 ms  10.0.0.1"
+func parseTracertLine(line, destIP string) (Hop, error) {
 	fields := strings.Fields(line)
 	if len(fields) < 3 || !isHopLine(fields[0]) {
 		return Hop{}, fmt.Errorf("not a hop line")
@@ -130,19 +237,31 @@ func parseTracertLine(line string) (Hop, error) {
 		return Hop{}, fmt.Errorf("invalid TTL")
 	}
 
+	hop := Hop{TTL: ttl}
 	ip := fields[len(fields)-1]
-	if ip == "*" {
-		return Hop{TTL: ttl, IP: "*", Timeout: true}, nil
+	if net.ParseIP(ip) == nil {
+		ip = ""
 	}
-
-	rttStr := fields[1]
-	if rttStr == "*" {
-		return Hop{TTL: ttl, IP: ip, Timeout: true}, nil
+	// tracert only reports the responder, so the type is implied by it
+	f := familyOf(destIP)
+	icmpType := number(f.timeExceeded)
+	if sameIP(ip, destIP) {
+		icmpType = number(f.echoReply)
 	}
-
-	var rtt float64
-	fmt.Sscanf(rttStr, "%f", &rtt)
-	return Hop{TTL: ttl, IP: ip, RTT: rtt, Timeout: false}, nil
+	for _, field := range fields[1:] {
+		switch {
+		case field == "*":
+			hop.Replies = append(hop.Replies, Reply{Timeout: true})
+		case field == "ms", net.ParseIP(field) != nil:
+		default:
+			var rtt float64
+			if _, err := fmt.Sscanf(strings.TrimPrefix(field, "<"), "%f", &rtt); err != nil {
+				continue
+			}
+			hop.Replies = append(hop.Replies, Reply{IP: ip, RTT: rtt, Type: icmpType, Timeout: ip == ""})
+		}
+	}
+	return hop, nil
 }
 
 // isHopLine checks if a line starts with a number (indicating a hop)
@@ -151,99 +270,220 @@ func isHopLine(firstField string) bool {
 	return err == nil
 }
 
-// runNonWindows performs an ICMP-based traceroute on non-Windows OSes
-func (t *Tracer) runNonWindows() error {
+// trace holds the sockets of a running non-Windows traceroute
+type trace struct {
+	*Tracer
+	dest      net.IP
+	local     net.IP         // Address the probes are sent from, for TCP checksums
+	localPort int            // Source port of UDP probes, base source port of TCP ones
+	icmpConn  net.PacketConn // Receives ICMP errors and echo replies
+	sendConn  net.PacketConn // Sends the probes: icmpConn, a UDP socket or a raw TCP socket
+	tcpConn   net.PacketConn // Receives TCP answers, nil unless tracing with TCP
+	packets   chan packet
+	done      chan struct{}
+}
+
+// packet is a packet read by one of the sockets of a trace
+type packet struct {
+	data []byte
+	from net.IP
+	at   time.Time
+	tcp  bool // Read from tcpConn rather than icmpConn
+}
+
+// runNonWindows performs an ICMP, UDP or TCP traceroute over IPv4 or IPv6
+// on non-Windows OSes
+func (t *Tracer) runNonWindows(ctx context.Context, emit func(Hop)) error {
 	if !t.Privileged {
-		t.updateText("Unprivileged mode not implemented; run with sudo for ICMP")
 		return fmt.Errorf("unprivileged mode not implemented; run with sudo for ICMP")
 	}
 
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
-	if err != nil {
-		t.updateText(fmt.Sprintf("Failed to listen for ICMP: %v (run with admin privileges?)", err))
+	tr := &trace{Tracer: t, dest: net.ParseIP(t.DestIP), packets: make(chan packet, 16), done: make(chan struct{})}
+	defer tr.close()
+	if err := tr.open(ctx); err != nil {
 		return err
 	}
-	defer conn.Close()
 
+	packetConn := t.family.packetConn(tr.sendConn)
+	if err := packetConn.setDSCP(t.DSCP); err != nil {
+		return fmt.Errorf("failed to set DSCP: %w", err)
+	}
+
+	seq := 0
 	for ttl := 1; ttl <= t.MaxHops; ttl++ {
-		err := conn.IPv4PacketConn().SetTTL(ttl)
-		if err != nil {
-			t.updateText(fmt.Sprintf("Failed to set TTL: %v", err))
-			return err
+		if err := packetConn.setHops(ttl); err != nil {
+			return fmt.Errorf("failed to set TTL: %w", err)
 		}
 
-		msg := icmp.Message{
-			Type: ipv4.ICMPTypeEcho, Code: 0,
-			Body: &icmp.Echo{
-				ID:   12345,
-				Seq:  ttl,
-				Data: []byte("IPmaster"),
-			},
+		hop := Hop{TTL: ttl}
+		reached := false
+		for range max(t.Probes, 1) {
+			seq++
+			reply, err := tr.probe(ctx, seq)
+			if err != nil {
+				return err
+			}
+			hop.Replies = append(hop.Replies, reply)
+			// Nothing gets further than an unreachable destination
+			reached = reached || sameIP(reply.IP, t.DestIP) || (!reply.Timeout && reply.Type == number(t.family.unreachable))
 		}
-		msgBytes, err := msg.Marshal(nil)
-		if err != nil {
-			t.updateText(fmt.Sprintf("Failed to marshal ICMP message: %v", err))
-			return err
+		emit(hop)
+
+		if reached {
+			break
 		}
+	}
+	return nil
+}
 
-		start := time.Now()
-		_, err = conn.WriteTo(msgBytes, &net.IPAddr{IP: net.ParseIP(t.DestIP)})
+// open creates the sockets of the trace and starts reading from them
+func (tr *trace) open(ctx context.Context) error {
+	lc := tr.Source.ListenConfig()
+	address := tr.Source.ListenAddress(tr.family.ipv6)
+	var err error
+	tr.icmpConn, err = lc.ListenPacket(ctx, tr.family.network, address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for ICMP: %w (run with admin privileges?)", err)
+	}
+	if err := tr.family.filterICMP(tr.icmpConn); err != nil {
+		return fmt.Errorf("failed to set ICMPv6 filter: %w", err)
+	}
+	go tr.read(tr.icmpConn, false)
+
+	switch tr.Method {
+	case MethodUDP:
+		conn, err := lc.ListenPacket(ctx, "udp"+tr.family.version, net.JoinHostPort(address, "0"))
 		if err != nil {
-			t.updateText(fmt.Sprintf("Failed to send ICMP packet: %v", err))
-			return err
+			return fmt.Errorf("failed to open UDP socket: %w", err)
+		}
+		tr.sendConn, tr.localPort = conn, conn.LocalAddr().(*net.UDPAddr).Port
+	case MethodTCP:
+		if tr.local, err = tr.localAddress(); err != nil {
+			return fmt.Errorf("failed to find the local address: %w", err)
+		}
+		if tr.tcpConn, err = lc.ListenPacket(ctx, "ip"+tr.family.version+":tcp", address); err != nil {
+			return fmt.Errorf("failed to open raw TCP socket: %w", err)
 		}
+		// Raw TCP probes have no socket of their own, so take source ports
+		// from the upper ephemeral range where they are unlikely to clash
+		tr.sendConn, tr.localPort = tr.tcpConn, 49152+tr.id%15360
+		go tr.read(tr.tcpConn, true)
+	default:
+		tr.sendConn = tr.icmpConn
+	}
+	return nil
+}
 
-		conn.SetReadDeadline(time.Now().Add(t.Timeout))
-		reply := make([]byte, 1500)
-		_, peer, err := conn.ReadFrom(reply)
-		if err != nil {
-			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				t.addHop(ttl, "*", "N/A", true, "N/A")
-				continue
-			}
-			t.updateText(fmt.Sprintf("Failed to read ICMP reply: %v", err))
-			return err
+// close stops the readers and closes the sockets of the trace
+func (tr *trace) close() {
+	close(tr.done)
+	conns := []net.PacketConn{tr.icmpConn, tr.tcpConn}
+	// ICMP and TCP probes are sent on one of the reading sockets
+	if tr.Method == MethodUDP {
+		conns = append(conns, tr.sendConn)
+	}
+	for _, conn := range conns {
+		if conn != nil {
+			conn.Close()
 		}
+	}
+}
 
-		rtt := time.Since(start).Seconds() * 1000
-		ip := peer.(*net.IPAddr).IP.String()
-		location, err := getIPLocation(ip)
+// localAddress returns the address probes to the destination are sent from
+func (tr *trace) localAddress() (net.IP, error) {
+	conn, err := tr.Source.Dialer("udp", tr.Timeout).Dial("udp", net.JoinHostPort(tr.DestIP, "9"))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// read passes the packets arriving on conn to probe until the trace ends
+func (tr *trace) read(conn net.PacketConn, tcp bool) {
+	for {
+		buf := make([]byte, 1500)
+		n, peer, err := conn.ReadFrom(buf)
 		if err != nil {
-			log.Printf("Location fetch error for %s: %v", ip, err)
+			select {
+			case <-tr.done:
+			default:
+				log.Printf("Traceroute read error: %v", err)
+			}
+			return
 		}
-		t.addHop(ttl, ip, fmt.Sprintf("%.2f ms", rtt), false, location)
-
-		if ip == t.DestIP {
-			break
+		select {
+		case tr.packets <- packet{data: buf[:n], from: peer.(*net.IPAddr).IP, at: time.Now(), tcp: tcp}:
+		case <-tr.done:
+			return
 		}
 	}
-
-	t.updateText(t.traceText.String())
-	return nil
 }
 
-// addHop adds a hop to the trace text and updates the TUI
-func (t *Tracer) addHop(ttl int, ip, rtt string, timeout bool, location string) {
-	hop := Hop{TTL: ttl, IP: ip, Location: location, Timeout: timeout}
-	if !timeout {
-		fmt.Sscanf(rtt, "%f ms", &hop.RTT)
+// probe sends probe seq with the current TTL and waits for the answer to it,
+// skipping packets meant for other probes or processes
+func (tr *trace) probe(ctx context.Context, seq int) (Reply, error) {
+	start := time.Now()
+	if err := tr.send(seq); err != nil {
+		return Reply{}, fmt.Errorf("failed to send probe: %w", err)
+	}
+
+	// The timer covers the whole wait, however many unrelated packets arrive
+	timer := time.NewTimer(tr.Timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return Reply{}, ctx.Err()
+		case <-timer.C:
+			return Reply{Timeout: true}, nil
+		case p := <-tr.packets:
+			reply := Reply{IP: p.from.String(), RTT: p.at.Sub(start).Seconds() * 1000}
+			if p.tcp {
+				if reply.TCP = tr.tcpAnswer(p.data, p.from, seq); reply.TCP != "" {
+					reply.Type, reply.Code = -1, -1
+					return reply, nil
+				}
+				continue
+			}
+
+			m, err := icmp.ParseMessage(tr.family.proto, p.data)
+			if err != nil {
+				continue
+			}
+			matched := false
+			switch body := m.Body.(type) {
+			case *icmp.Echo:
+				matched = tr.Method == MethodICMP && m.Type == tr.family.echoReply && body.ID == tr.id && body.Seq == seq
+			case *icmp.TimeExceeded:
+				matched = tr.matchesQuoted(body.Data, seq)
+			case *icmp.DstUnreach:
+				matched = tr.matchesQuoted(body.Data, seq)
+			}
+			if matched {
+				reply.Type, reply.Code = number(m.Type), m.Code
+				return reply, nil
+			}
+		}
 	}
-	t.hops = append(t.hops, hop)
-	t.traceText.WriteString(fmt.Sprintf("Hop %2d: %s (%s) - %s\n", ttl, ip, location, rtt))
-	t.updateText(t.traceText.String())
 }
 
-// updateText updates the TextView with the current trace text
-func (t *Tracer) updateText(text string) {
-	t.app.QueueUpdateDraw(func() {
-		t.resultView.SetText(text)
-	})
-	t.app.Draw()
+// location returns the location of ip on ipinfo.io, logging failures
+func location(ctx context.Context, ip string) string {
+	location, err := getIPLocation(ctx, ip)
+	if err != nil {
+		log.Printf("Location fetch error for %s: %v", ip, err)
+	}
+	return location
 }
 
 // getIPLocation fetches geolocation data for an IP using ipinfo.io
-func getIPLocation(ip string) (string, error) {
-	resp, err := http.Get(fmt.Sprintf("http://ipinfo.io/%s/json", ip))
+func getIPLocation(ctx context.Context, ip string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://ipinfo.io/%s/json", ip), nil)
+	if err != nil {
+		return "Unknown", err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "Unknown", fmt.Errorf("failed to fetch location for %s: %v", ip, err)
 	}
@@ -258,4 +498,4 @@ func getIPLocation(ip string) (string, error) {
 		return "Unknown", nil
 	}
 	return fmt.Sprintf("%s, %s, %s", info.City, info.Region, info.Country), nil
-}
\ No newline at end of file
+}
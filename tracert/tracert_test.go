@@ -0,0 +1,138 @@
+package tracert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTracertLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		dest string
+		want Hop
+	}{
+		{
+			name: "router",
+			line: "  1    <1 ms    <1 ms    <1 ms  192.168.1.1",
+			dest: "8.8.8.8",
+			want: Hop{TTL: 1, Replies: []Reply{
+				{IP: "192.168.1.1", RTT: 1, Type: 11},
+				{IP: "192.168.1.1", RTT: 1, Type: 11},
+				{IP: "192.168.1.1", RTT: 1, Type: 11},
+			}},
+		},
+		{
+			name: "partly answered",
+			line: " 12    12 ms     *       11 ms  10.0.0.1",
+			dest: "8.8.8.8",
+			want: Hop{TTL: 12, Replies: []Reply{
+				{IP: "10.0.0.1", RTT: 12, Type: 11},
+				{Timeout: true},
+				{IP: "10.0.0.1", RTT: 11, Type: 11},
+			}},
+		},
+		{
+			name: "timed out",
+			line: "  3     *        *        *     Request timed out.",
+			dest: "8.8.8.8",
+			want: Hop{TTL: 3, Replies: []Reply{{Timeout: true}, {Timeout: true}, {Timeout: true}}},
+		},
+		{
+			name: "destination",
+			line: "  4    15 ms    14 ms    14 ms  8.8.8.8",
+			dest: "8.8.8.8",
+			want: Hop{TTL: 4, Replies: []Reply{
+				{IP: "8.8.8.8", RTT: 15, Type: 0},
+				{IP: "8.8.8.8", RTT: 14, Type: 0},
+				{IP: "8.8.8.8", RTT: 14, Type: 0},
+			}},
+		},
+		{
+			name: "ipv6 router",
+			line: "  1    <1 ms    <1 ms     2 ms  2001:db8::1",
+			dest: "2001:db8::9",
+			want: Hop{TTL: 1, Replies: []Reply{
+				{IP: "2001:db8::1", RTT: 1, Type: 3},
+				{IP: "2001:db8::1", RTT: 1, Type: 3},
+				{IP: "2001:db8::1", RTT: 2, Type: 3},
+			}},
+		},
+		{
+			name: "ipv6 destination",
+			line: "  2     5 ms     4 ms     4 ms  2001:db8::9",
+			dest: "2001:db8::9",
+			want: Hop{TTL: 2, Replies: []Reply{
+				{IP: "2001:db8::9", RTT: 5, Type: 129},
+				{IP: "2001:db8::9", RTT: 4, Type: 129},
+				{IP: "2001:db8::9", RTT: 4, Type: 129},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTracertLine(tt.line, tt.dest)
+			if err != nil {
+				t.Fatalf("parseTracertLine(%q) failed: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTracertLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTracertLineSkipsOtherLines(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"Tracing route to 8.8.8.8 over a maximum of 30 hops",
+		"over a maximum of 30 hops:",
+		"Trace complete.",
+		"  0    <1 ms    <1 ms    <1 ms  192.168.1.1",
+	} {
+		if hop, err := parseTracertLine(line, "8.8.8.8"); err == nil {
+			t.Errorf("parseTracertLine(%q) = %+v, want an error", line, hop)
+		}
+	}
+}
+
+func TestNewTracerCanonicalizesDest(t *testing.T) {
+	tests := []struct {
+		dest string
+		want string
+		ipv6 bool
+	}{
+		{"198.51.100.9", "198.51.100.9", false},
+		{"2001:db8:0::1", "2001:db8::1", true},
+		{"2001:DB8::1", "2001:db8::1", true},
+		{"::ffff:198.51.100.9", "198.51.100.9", false},
+	}
+	for _, tt := range tests {
+		tr, err := NewTracer(tt.dest)
+		if err != nil {
+			t.Fatalf("NewTracer(%q) failed: %v", tt.dest, err)
+		}
+		if tr.DestIP != tt.want || tr.family.ipv6 != tt.ipv6 {
+			t.Errorf("NewTracer(%q) has DestIP %q, IPv6 %v, want %q, %v", tt.dest, tr.DestIP, tr.family.ipv6, tt.want, tt.ipv6)
+		}
+	}
+	if _, err := NewTracer("2001:db8::zz"); err == nil {
+		t.Errorf("NewTracer accepted an invalid address")
+	}
+}
+
+func TestReached(t *testing.T) {
+	tr, _ := NewTracer("2001:db8:0::1")
+	tr.hops = []Hop{{TTL: 1, IP: "2001:db8::fe"}}
+	if tr.Reached() {
+		t.Errorf("Reached() with only a router answering")
+	}
+	tr.hops = append(tr.hops, Hop{TTL: 2, IP: "2001:db8::1"})
+	if !tr.Reached() {
+		t.Errorf("Reached() = false after the destination answered")
+	}
+	tr.hops = append(tr.hops, Hop{TTL: 3, IP: "*", Timeout: true})
+	if tr.Reached() {
+		t.Errorf("Reached() with a timed out last hop")
+	}
+}
@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/alert"
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// maxEvents bounds the number of lines kept in the alert event log
+const maxEvents = 200
+
+// showAlertForm replaces the screen with a form editing the alert rules and
+// actions. onSave receives the edited configuration and onClose restores the
+// previous screen.
+func showAlertForm(app *tview.Application, alerts config.Alerts, onSave func(config.Alerts) error, onClose func()) {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle(" Alerts ")
+
+	intText := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+	degraded := ""
+	if alerts.Degraded != 0 {
+		degraded = time.Duration(alerts.Degraded).String()
+	}
+	defaults := alert.DefaultRules()
+
+	form.AddInputField(fmt.Sprintf("Down after losses (default %d)", defaults.DownAfter), intText(alerts.DownAfter), 6, tview.InputFieldInteger, nil)
+	form.AddInputField(fmt.Sprintf("Up after replies (default %d)", defaults.UpAfter), intText(alerts.UpAfter), 6, tview.InputFieldInteger, nil)
+	form.AddInputField("Degraded above avg RTT (e.g. 150ms)", degraded, 12, nil, nil)
+	form.AddCheckbox("Ring terminal bell", !alerts.Quiet, nil)
+	form.AddTextArea("Commands (one per line)", strings.Join(alerts.Commands, "\n"), 60, 3, 0, nil)
+	form.AddTextArea("Webhook URLs (one per line)", strings.Join(alerts.Webhooks, "\n"), 60, 3, 0, nil)
+
+	status := tview.NewTextView().SetDynamicColors(true).
+		SetText("[grey]Commands get IPMASTER_TARGET, IPMASTER_STATE, IPMASTER_FROM, IPMASTER_RTT_MS, IPMASTER_REASON and more")
+
+	form.AddButton("Save", func() {
+		edited, err := readAlertForm(form)
+		if err == nil {
+			err = onSave(edited)
+		}
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+			return
+		}
+		onClose()
+	})
+	form.AddButton("Cancel", onClose)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 1, false)
+	app.SetRoot(flex, true)
+	app.SetFocus(form)
+}
+
+// readAlertForm parses the fields created by showAlertForm, in order
+func readAlertForm(form *tview.Form) (config.Alerts, error) {
+	text := func(i int) string {
+		return strings.TrimSpace(form.GetFormItem(i).(*tview.InputField).GetText())
+	}
+	lines := func(i int) []string {
+		var out []string
+		for _, line := range strings.Split(form.GetFormItem(i).(*tview.TextArea).GetText(), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				out = append(out, line)
+			}
+		}
+		return out
+	}
+
+	var a config.Alerts
+	var err error
+	if text(0) != "" {
+		if a.DownAfter, err = strconv.Atoi(text(0)); err != nil || a.DownAfter < 1 {
+			return a, fmt.Errorf("down after: must be at least 1")
+		}
+	}
+	if text(1) != "" {
+		if a.UpAfter, err = strconv.Atoi(text(1)); err != nil || a.UpAfter < 1 {
+			return a, fmt.Errorf("up after: must be at least 1")
+		}
+	}
+	if text(2) != "" {
+		d, err := time.ParseDuration(text(2))
+		if err != nil || d <= 0 {
+			return a, fmt.Errorf("degraded: invalid duration %q", text(2))
+		}
+		a.Degraded = config.Duration(d)
+	}
+	a.Quiet = !form.GetFormItem(3).(*tview.Checkbox).IsChecked()
+	a.Commands = lines(4)
+	a.Webhooks = lines(5)
+	for _, hook := range a.Webhooks {
+		if u, err := url.Parse(hook); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return a, fmt.Errorf("invalid webhook URL %q", hook)
+		}
+	}
+	return a, nil
+}
+
+// alertEventText formats an event for the event log, colored by the new state
+func alertEventText(ev alert.Event) string {
+	return fmt.Sprintf("[%s]%s", alertStateColor(ev.To), tview.Escape(ev.String()))
+}
+
+// alertStateColor is the color used for a target state
+func alertStateColor(state alert.State) string {
+	switch state {
+	case alert.Up:
+		return "green"
+	case alert.Degraded:
+		return "yellow"
+	case alert.Down:
+		return "red"
+	}
+	return "grey"
+}
+
+// newEventLog creates the pane that lists alert events, newest last
+func newEventLog() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetMaxLines(maxEvents)
+	view.SetBorder(true).SetTitle(" Events ").SetTitleColor(tcell.ColorGrey)
+	return view
+}
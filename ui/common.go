@@ -1,10 +1,26 @@
 package ui
 
 import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/a-tharva/ipmaster/resolve"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
+// bellPending asks the next draw to ring the terminal bell
+var bellPending atomic.Bool
+
+// ringBell rings the terminal bell on the next redraw
+func ringBell() {
+	bellPending.Store(true)
+}
+
 // Function to update the style of the selected cell
 func updateSelectedStyle(table *tview.Table, selectedRow int) {
 	for i := 0; i < len(ipOptions); i++ {
@@ -21,9 +37,114 @@ func setBackCapture(app *tview.Application) {
 		switch event.Key() {
 		case 'b', tcell.KeyEscape:
 			stopContinuousPing()
+			stopSweep()
+			stopTrace()
 			Create(app)
 			return nil
 		}
 		return event
 	})
 }
+
+// setTableHeaders writes a header row into row 0 of table
+func setTableHeaders(table *tview.Table, headers []string) {
+	for i, header := range headers {
+		table.SetCell(0, i,
+			tview.NewTableCell(header).SetTextColor(tview.Styles.SecondaryTextColor).SetAlign(tview.AlignCenter))
+	}
+}
+
+// newFamilyDropDown creates a selector for the address family hostnames resolve to
+func newFamilyDropDown() *tview.DropDown {
+	var options []string
+	for _, family := range resolve.Families {
+		options = append(options, family.String())
+	}
+	return tview.NewDropDown().
+		SetLabel("Family: ").
+		SetOptions(options, nil).
+		SetCurrentOption(0)
+}
+
+// selectedFamily returns the family chosen in a dropdown from newFamilyDropDown
+func selectedFamily(dropDown *tview.DropDown) resolve.Family {
+	index, _ := dropDown.GetCurrentOption()
+	if index < 0 || index >= len(resolve.Families) {
+		return resolve.Any
+	}
+	return resolve.Families[index]
+}
+
+// defaultSource is the source option leaving the choice to the routing table
+const defaultSource = "(default)"
+
+// newSourceDropDown creates a selector for the interface or address probes
+// are sent from, offering the interfaces from ipinfo.Sources
+func newSourceDropDown(current string) *tview.DropDown {
+	options := []string{defaultSource}
+	selected := 0
+	sources, err := ipinfo.Sources()
+	if err != nil {
+		log.Printf("Failed to list source interfaces: %v", err)
+	}
+	for _, source := range sources {
+		if source.String() == current {
+			selected = len(options)
+		}
+		options = append(options, source.Label())
+	}
+	if current != "" && selected == 0 {
+		// Keep a configured source that is currently missing, e.g. a VPN that is down
+		selected = len(options)
+		options = append(options, current)
+	}
+	return tview.NewDropDown().
+		SetLabel("Source: ").
+		SetOptions(options, nil).
+		SetCurrentOption(selected)
+}
+
+// selectedSource returns the source chosen in a dropdown from
+// newSourceDropDown, or "" for the default
+func selectedSource(dropDown *tview.DropDown) string {
+	_, text := dropDown.GetCurrentOption()
+	if text == defaultSource || text == "" {
+		return ""
+	}
+	// Address options read "10.0.0.5 (eth0)"
+	return strings.Fields(text)[0]
+}
+
+// resolutionText describes how a host resolved, e.g. "example.com → 93.184.216.34 (12ms)"
+func resolutionText(res resolve.Result) string {
+	if res.Addr == nil {
+		return res.Host
+	}
+	if res.Addr.String() == res.Host {
+		return res.Host
+	}
+	return fmt.Sprintf("%s → %s (%s)", res.Host, res.Addr, res.Duration.Round(time.Microsecond))
+}
+
+// setFocusCycle lets Tab and Backtab move focus between items inside container
+func setFocusCycle(app *tview.Application, container *tview.Flex, items ...tview.Primitive) {
+	container.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		var step int
+		switch event.Key() {
+		case tcell.KeyTab:
+			step = 1
+		case tcell.KeyBacktab:
+			step = -1
+		default:
+			return event
+		}
+		for i, item := range items {
+			if item.HasFocus() {
+				app.SetFocus(items[(i+step+len(items))%len(items)])
+				return nil
+			}
+		}
+		app.SetFocus(items[0])
+		return nil
+	})
+}
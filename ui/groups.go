@@ -0,0 +1,102 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/rivo/tview"
+)
+
+// noGroup is the group dropdown entry for typing targets by hand
+const noGroup = "(none)"
+
+// showGroupForm replaces the screen with a form editing the group called
+// name, prefilled with targets. Saving or deleting writes the configuration;
+// onClose restores the previous screen and receives the name of the saved
+// group, or "" if nothing was saved.
+func showGroupForm(app *tview.Application, cfg *config.Config, name string, targets []config.GroupTarget, onClose func(saved string)) {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle(" Target group ")
+
+	form.AddInputField("Name", name, 30, nil, nil)
+	form.AddTextArea("Targets (target, label per line)", config.FormatGroupTargets(targets), 60, 10, 0, nil)
+	form.AddInputField("Import from text/CSV file", "", 60, nil, nil)
+
+	status := tview.NewTextView().SetDynamicColors(true).
+		SetText("[grey]Import replaces the target list; Save to keep it")
+	fail := func(err error) {
+		status.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+	}
+	nameField := form.GetFormItem(0).(*tview.InputField)
+	targetsArea := form.GetFormItem(1).(*tview.TextArea)
+	importField := form.GetFormItem(2).(*tview.InputField)
+
+	form.AddButton("Save", func() {
+		groupName := strings.TrimSpace(nameField.GetText())
+		if groupName == "" || groupName == noGroup {
+			fail(fmt.Errorf("enter a group name"))
+			return
+		}
+		parsed, err := config.ParseGroupTargets(strings.NewReader(targetsArea.GetText()))
+		if err != nil {
+			fail(err)
+			return
+		}
+		if len(parsed) == 0 {
+			fail(fmt.Errorf("a group needs at least one target"))
+			return
+		}
+		if groupName != name {
+			cfg.DeleteGroup(name) // Renamed
+		}
+		cfg.SetGroup(config.Group{Name: groupName, Targets: parsed})
+		if err := cfg.Save(); err != nil {
+			fail(err)
+			return
+		}
+		onClose(groupName)
+	})
+	form.AddButton("Import", func() {
+		path := strings.TrimSpace(importField.GetText())
+		if path == "" {
+			fail(fmt.Errorf("enter the file to import"))
+			return
+		}
+		imported, err := config.ImportGroupTargets(path)
+		if err != nil {
+			fail(err)
+			return
+		}
+		targetsArea.SetText(config.FormatGroupTargets(imported), false)
+		status.SetText(fmt.Sprintf("Imported %d targets from %s", len(imported), tview.Escape(path)))
+	})
+	form.AddButton("Delete", func() {
+		if _, ok := cfg.Group(name); !ok {
+			onClose("")
+			return
+		}
+		cfg.DeleteGroup(name)
+		if err := cfg.Save(); err != nil {
+			fail(err)
+			return
+		}
+		onClose("")
+	})
+	form.AddButton("Cancel", func() { onClose("") })
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 1, false)
+	app.SetRoot(flex, true)
+	app.SetFocus(form)
+}
+
+// groupOptions lists the dropdown entries for the saved groups
+func groupOptions(cfg *config.Config) []string {
+	options := []string{noGroup}
+	for _, g := range cfg.Groups {
+		options = append(options, g.Name)
+	}
+	return options
+}
@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/a-tharva/ipmaster/store"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const replayWidth = 60 // Characters per sparkline in a replayed session
+
+func showHistory(app *tview.Application) {
+	historyView := tview.NewTextView().
+		SetText("Ping History Page\n[grey]Enter: load session  m: monitor its targets again  Tab: switch tables").
+		SetTextAlign(tview.AlignCenter).
+		SetDynamicColors(true)
+
+	sessionTable := tview.NewTable().SetBorders(true).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	setTableHeaders(sessionTable, []string{"Started", "Size"})
+
+	summaryView := tview.NewTextView().SetDynamicColors(true)
+	replayTable := tview.NewTable().SetBorders(true).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+
+	dir, err := store.Dir()
+	var sessions []store.Session
+	if err == nil {
+		sessions, err = store.Sessions(dir)
+	}
+	switch {
+	case err != nil:
+		summaryView.SetText(fmt.Sprintf("[red]Failed to list sessions: %s", tview.Escape(err.Error())))
+	case len(sessions) == 0:
+		summaryView.SetText(fmt.Sprintf("No sessions recorded in %s yet", dir))
+	default:
+		summaryView.SetText(fmt.Sprintf("%d sessions in %s", len(sessions), dir))
+	}
+	for i, s := range sessions {
+		sessionTable.SetCell(i+1, 0, tview.NewTableCell(s.Start.Format("2006-01-02 15:04:05")).SetAlign(tview.AlignCenter))
+		sessionTable.SetCell(i+1, 1, tview.NewTableCell(fmt.Sprintf("%.1f KiB", float64(s.Size)/1024)).SetAlign(tview.AlignCenter))
+	}
+
+	// targets lists the targets of the loaded session, in replay table order
+	var targets []string
+
+	loadSession := func(row int) {
+		if row < 1 || row > len(sessions) {
+			return
+		}
+		s := sessions[row-1]
+		records, err := store.Load(s.Path)
+		if err != nil {
+			summaryView.SetText(fmt.Sprintf("[red]Failed to load %s: %s", s.Path, tview.Escape(err.Error())))
+			if len(records) == 0 {
+				return
+			}
+		}
+		replay := store.NewReplay(records, ping.DefaultWindow)
+
+		replayTable.Clear()
+		setTableHeaders(replayTable, []string{"Host", "Probe", "Sent/Recv", "Loss", "Min/Avg/Max", "StdDev", "Jitter",
+			"Final Loss Min/Avg/Max/StdDev/Jitter", "Dup/Reord/Late", "Outages", "History (whole session)"})
+		targets = nil
+		span := max(replay.End.Sub(replay.Start), time.Second)
+		for i, t := range replay.Targets {
+			row := i + 1
+			targets = append(targets, t.Target)
+			replayTable.SetCell(row, 0, tview.NewTableCell(t.Spec.Host).SetAlign(tview.AlignCenter))
+			replayTable.SetCell(row, 1, tview.NewTableCell(t.Spec.Label()).SetAlign(tview.AlignCenter))
+			setStatisticsCells(replayTable, row, 2, t.Session, t.Window)
+			replayTable.SetCell(row, 9, tview.NewTableCell(t.Outages.String()).SetAlign(tview.AlignCenter))
+			replayTable.SetCell(row, 10, tview.NewTableCell(sparkline(t.Samples, replay.End, span, replayWidth)).SetTextColor(tcell.ColorTeal))
+		}
+		if err == nil {
+			summaryView.SetText(fmt.Sprintf("Session %s: %d results for %d targets over %s",
+				s.Start.Format("2006-01-02 15:04:05"), len(records), len(replay.Targets), span.Round(time.Second)))
+		}
+		app.SetFocus(replayTable)
+	}
+
+	sessionTable.SetSelectedFunc(func(row, _ int) { loadSession(row) })
+	replayTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() == 'm' && len(targets) > 0 {
+			showPingTargets(app, targets)
+			return nil
+		}
+		return event
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(historyView, 2, 1, false).
+		AddItem(sessionTable, 0, 2, true).
+		AddItem(summaryView, 1, 1, false).
+		AddItem(replayTable, 0, 3, false)
+	setFocusCycle(app, flex, sessionTable, replayTable)
+
+	app.SetRoot(flex, true)
+	app.SetFocus(sessionTable)
+	setBackCapture(app)
+}
@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a-tharva/ipmaster/httpprobe"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func showHTTP(app *tview.Application) {
+	httpView := tview.NewTextView().
+		SetText("HTTP Probe Page").SetTextAlign(tview.AlignCenter)
+
+	urlField := tview.NewInputField().
+		SetLabel("Enter URL: ").
+		SetPlaceholder("https://example.com/health").
+		SetFieldWidth(0)
+	headersField := tview.NewInputField().
+		SetLabel("Headers (Name: value; ...): ").
+		SetFieldWidth(0)
+	statusField := tview.NewInputField().
+		SetLabel("Expected status (blank: any below 400): ").
+		SetAcceptanceFunc(tview.InputFieldInteger).
+		SetFieldWidth(5)
+	bodyField := tview.NewInputField().
+		SetLabel("Body must contain: ").
+		SetFieldWidth(0)
+
+	resultView := tview.NewTextView().
+		SetText("Enter a URL and press Enter to time a request...").
+		SetDynamicColors(true).
+		SetWordWrap(true)
+
+	runProbe := func() {
+		url := strings.TrimSpace(urlField.GetText())
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			urlField.SetFieldBackgroundColor(tcell.ColorRed)
+			resultView.SetText("URL must start with http:// or https://")
+			return
+		}
+		urlField.SetFieldBackgroundColor(tcell.ColorBlue)
+
+		opts := httpprobe.DefaultOptions()
+		headers, err := parseHeaders(headersField.GetText())
+		if err != nil {
+			resultView.SetText(tview.Escape(err.Error()))
+			return
+		}
+		opts.Headers = make(http.Header)
+		for name, value := range headers {
+			opts.Headers.Set(name, value)
+		}
+		if text := strings.TrimSpace(statusField.GetText()); text != "" {
+			opts.ExpectStatus, _ = strconv.Atoi(text)
+		}
+		opts.ExpectBody = bodyField.GetText()
+
+		resultView.SetText(fmt.Sprintf("Requesting %s...", tview.Escape(url)))
+		go func() {
+			timing, err := httpprobe.Probe(url, opts)
+			app.QueueUpdateDraw(func() {
+				resultView.SetText(httpTimingText(url, timing, err))
+			})
+		}()
+	}
+
+	for _, field := range []*tview.InputField{urlField, headersField, statusField, bodyField} {
+		field.SetDoneFunc(func(key tcell.Key) {
+			if key == tcell.KeyEnter {
+				runProbe()
+			}
+		})
+	}
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(httpView, 0, 1, false).
+		AddItem(urlField, 1, 1, true).
+		AddItem(headersField, 1, 1, false).
+		AddItem(statusField, 1, 1, false).
+		AddItem(bodyField, 1, 1, false).
+		AddItem(resultView, 0, 5, false)
+	setFocusCycle(app, flex, urlField, headersField, statusField, bodyField)
+
+	app.SetRoot(flex, true)
+	app.SetFocus(urlField)
+	setBackCapture(app)
+}
+
+// httpTimingText formats the timing breakdown of an HTTP probe
+func httpTimingText(url string, t httpprobe.Timing, err error) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Request to %s\n", tview.Escape(url)))
+	b.WriteString("--------------------------------------------------\n")
+	if t.Addr != "" {
+		b.WriteString(fmt.Sprintf("Remote address:  %s\n", t.Addr))
+	}
+	b.WriteString(fmt.Sprintf("DNS lookup:      %s ms\n", formatMs(t.DNS)))
+	b.WriteString(fmt.Sprintf("TCP connect:     %s ms\n", formatMs(t.Connect)))
+	b.WriteString(fmt.Sprintf("TLS handshake:   %s ms\n", formatMs(t.TLS)))
+	b.WriteString(fmt.Sprintf("First byte:      %s ms\n", formatMs(t.FirstByte)))
+	b.WriteString(fmt.Sprintf("Total:           %s ms\n", formatMs(t.Total)))
+	if t.Status != 0 {
+		b.WriteString(fmt.Sprintf("Status:          %d %s\n", t.Status, http.StatusText(t.Status)))
+		b.WriteString(fmt.Sprintf("Response size:   %d bytes\n", t.Size))
+	}
+	if err != nil {
+		b.WriteString(fmt.Sprintf("\n[red]Failed: %s", tview.Escape(err.Error())))
+	} else {
+		b.WriteString("\n[green]OK")
+	}
+	return b.String()
+}
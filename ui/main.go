@@ -12,10 +12,20 @@ var ipOptions = []string{
 	"port",
 	"ip tables",
 	"bgp",
+	"sweep",
+	"http",
+	"history",
+	"pmtu",
 }
 
 func Start() error {
 	app := tview.NewApplication()
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		if bellPending.Swap(false) {
+			screen.Beep()
+		}
+		return false
+	})
 	Create(app)
 	return app.Run()
 }
@@ -72,6 +82,14 @@ func handleTableSelection(app *tview.Application, row int) {
 		showIPTables(app)
 	case 5:
 		showBGP(app)
+	case 6:
+		showSweep(app)
+	case 7:
+		showHTTP(app)
+	case 8:
+		showHistory(app)
+	case 9:
+		showPMTU(app)
 	}
 }
 
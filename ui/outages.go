@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/logging"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const timelineWidth = 60 // Characters in the outage timeline strip
+
+// newTimelineView creates the pane showing the outages of the selected target
+func newTimelineView() *tview.TextView {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetText("[grey]Select a target to see its outages")
+	view.SetBorder(true).SetTitle(" Outages ").SetTitleColor(tcell.ColorGrey)
+	return view
+}
+
+// outageTimelineText renders a strip of the span before now, red where the
+// target was down, followed by the recent outages newest first
+func outageTimelineText(outages []ping.Outage, summary ping.OutageSummary, now time.Time, span time.Duration) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s (last %s)\n%s\n", summary, formatWindow(span), outageStrip(outages, now, span, timelineWidth))
+	for i := len(outages) - 1; i >= 0; i-- {
+		o := outages[i]
+		end := "now"
+		if !o.Ongoing() {
+			end = o.End.Format("15:04:05")
+		}
+		fmt.Fprintf(&b, "[red]%s → %-8s[white] %6s  %d lost\n",
+			o.Start.Format("15:04:05"), end, o.Duration(now).Round(time.Second), o.Lost)
+	}
+	return b.String()
+}
+
+// outageStrip renders span before until as width characters, red where an
+// outage overlaps and green elsewhere
+func outageStrip(outages []ping.Outage, until time.Time, span time.Duration, width int) string {
+	start := until.Add(-span)
+	bucket := span / time.Duration(width)
+	down := make([]bool, width)
+	for _, o := range outages {
+		end := o.End
+		if o.Ongoing() {
+			end = until
+		}
+		if end.Before(start) {
+			continue
+		}
+		first := max(int(o.Start.Sub(start)/bucket), 0)
+		last := min(int(end.Sub(start)/bucket), width-1)
+		for i := first; i <= last; i++ {
+			down[i] = true
+		}
+	}
+
+	var b strings.Builder
+	color := ""
+	for _, d := range down {
+		next, char := "[green]", "─"
+		if d {
+			next, char = "[red]", "█"
+		}
+		if next != color {
+			b.WriteString(next)
+			color = next
+		}
+		b.WriteString(char)
+	}
+	b.WriteString("[white]")
+	return b.String()
+}
+
+// exportPing writes the statistics and outage counts of the monitored
+// targets to a timestamped CSV file in the data directory
+func exportPing(monitor *ping.Monitor, targets []string, cfg *config.Config) (string, error) {
+	path := filepath.Join(logging.GetDataDir(), fmt.Sprintf("ping-%s.csv", time.Now().Format("20060102-150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"target", "label", "probe", "sent", "recv", "loss", "min_ms", "avg_ms", "max_ms",
+		"stddev_ms", "jitter_ms", "duplicates", "reordered", "late", "outages", "longest_outage_s", "outage_lost"})
+	for _, target := range targets {
+		spec, _ := ping.ParseSpec(target)
+		session, _ := monitor.Statistics(target)
+		_, outages := monitor.Outages(target)
+		w.Write([]string{
+			target,
+			cfg.Label(target),
+			spec.Label(),
+			strconv.Itoa(session.Sent),
+			strconv.Itoa(session.Recv),
+			fmt.Sprintf("%.1f", session.Loss),
+			formatMs(session.MinRTT),
+			formatMs(session.AvgRTT),
+			formatMs(session.MaxRTT),
+			formatMs(session.StdDevRTT),
+			formatMs(session.Jitter),
+			strconv.Itoa(session.Anomalies.Duplicates),
+			strconv.Itoa(session.Anomalies.Reordered),
+			strconv.Itoa(session.Anomalies.Late),
+			strconv.Itoa(outages.Count),
+			fmt.Sprintf("%.0f", outages.Longest.Seconds()),
+			strconv.Itoa(outages.Lost),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return path, nil
+}
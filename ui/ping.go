@@ -0,0 +1,471 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/alert"
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/metrics"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/a-tharva/ipmaster/resolve"
+	"github.com/a-tharva/ipmaster/store"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+var (
+	pingMonitor  *ping.Monitor   // Monitor backing the Ping page, nil when the page is closed
+	pingRecorder *store.Recorder // Writes the Ping page results to disk, nil when disabled
+	pingMetrics  *metrics.Exporter
+)
+
+// SetMetrics makes the Ping page report its results to e
+func SetMetrics(e *metrics.Exporter) {
+	pingMetrics = e
+}
+
+func showPing(app *tview.Application) {
+	showPingTargets(app, nil)
+}
+
+// showPingTargets opens the Ping page and starts monitoring targets, if any
+func showPingTargets(app *tview.Application, targets []string) {
+	pingView := tview.NewTextView().
+		SetText("Ping Page").SetTextAlign(tview.AlignCenter).
+		SetDynamicColors(true)
+
+	inputField := tview.NewInputField().
+		SetLabel("Enter hosts (comma-separated): ").
+		SetPlaceholder("e.g. 8.8.8.8, 8.8.8.8#ef, example.com:tcp/443, 10.0.0.5:udp/53, https://example.com").
+		SetFieldWidth(0)
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Using default ping settings: %v", err)
+	}
+
+	groupDropDown := tview.NewDropDown().
+		SetLabel("Group: ").
+		SetOptions(groupOptions(cfg), nil).
+		SetCurrentOption(0)
+	familyDropDown := newFamilyDropDown()
+	reResolveBox := tview.NewCheckbox().SetLabel("Re-resolve each probe: ")
+
+	resultTable := tview.NewTable().SetBorders(true).
+		SetSelectable(true, false).
+		SetFixed(1, 0)
+	headers := []string{"Host", "Probe", "Status", "Sent/Recv", "Loss", "Min/Avg/Max", "StdDev", "Jitter", "Recent Loss Min/Avg/Max/StdDev/Jitter", "Dup/Reord/Late", "Outages", ""}
+	historyColumn := len(headers) - 1
+	outageColumn := historyColumn - 1
+
+	// rows maps each target to its table row, order lists targets by row,
+	// zoom indexes historyWindows and group names the loaded group, if any;
+	// all are only touched from the UI goroutine
+	rows := make(map[string]int)
+	var order []string
+	zoom := 0
+	group := ""
+
+	setHeaders := func() {
+		headers[historyColumn] = fmt.Sprintf("History (%s, z to zoom)", formatWindow(historyWindows[zoom]))
+		setTableHeaders(resultTable, headers)
+	}
+	setHeaders()
+
+	monitor := ping.NewMonitor()
+	pingView.SetText(fmt.Sprintf("Ping Page\n%s\n%s", pingModeText(monitor.Mode),
+		"[grey]Tab: focus table  s: target settings  d: default settings  a: alerts  g: edit group  e: export  z: zoom history"))
+
+	recorder := openRecorder(cfg.History)
+	if recorder != nil {
+		monitor.Subscribe(func(res ping.Result) {
+			if err := recorder.Record(res); err != nil && !errors.Is(err, os.ErrClosed) {
+				log.Printf("Failed to record ping history: %v", err)
+			}
+		})
+		monitor.SubscribeLate(func(target string, seq int, a ping.Anomalies) {
+			if err := recorder.RecordLate(target, seq, a); err != nil && !errors.Is(err, os.ErrClosed) {
+				log.Printf("Failed to record ping history: %v", err)
+			}
+		})
+	}
+
+	if pingMetrics != nil {
+		monitor.Subscribe(pingMetrics.Observe)
+	}
+
+	eventLog := newEventLog()
+	tracker := alert.NewTracker(cfg.Alerts.Rules())
+	timelineView := newTimelineView()
+
+	// renderTimeline shows the outages of the selected target
+	renderTimeline := func() {
+		row, _ := resultTable.GetSelection()
+		if row < 1 || row > len(order) {
+			timelineView.SetText("[grey]Select a target to see its outages")
+			return
+		}
+		target := order[row-1]
+		outages, summary := monitor.Outages(target)
+		timelineView.SetText(outageTimelineText(outages, summary, time.Now(), historyWindows[zoom]))
+	}
+	resultTable.SetSelectionChangedFunc(func(int, int) { renderTimeline() })
+
+	renderHistory := func(target string, row int) {
+		span := historyWindows[zoom]
+		now := time.Now()
+		line := sparkline(monitor.History(target, now.Add(-span)), now, span, historyWidth)
+		resultTable.SetCell(row, historyColumn, tview.NewTableCell(line).SetTextColor(tcell.ColorTeal))
+	}
+	monitor.Subscribe(func(res ping.Result) {
+		session, window := monitor.Statistics(res.Target)
+		settings := monitor.Settings(res.Target)
+		_, outages := monitor.Outages(res.Target)
+		ev, changed := tracker.Observe(res, window)
+		app.QueueUpdateDraw(func() {
+			if changed {
+				log.Printf("Alert: %s", ev)
+				alert.Dispatch(cfg.Alerts.Actions(), ev)
+				fmt.Fprintln(eventLog, alertEventText(ev))
+				eventLog.ScrollToEnd()
+				if !cfg.Alerts.Quiet {
+					ringBell()
+				}
+			}
+			row, ok := rows[res.Target]
+			if !ok {
+				return
+			}
+			resultTable.SetCell(row, 0, tview.NewTableCell(pingTargetText(res, cfg.Label(res.Target))).SetTextColor(tview.Styles.PrimaryTextColor).SetAlign(tview.AlignCenter))
+			status, color := pingResultStatus(res, settings)
+			resultTable.SetCell(row, 2, tview.NewTableCell(status).SetTextColor(color).SetAlign(tview.AlignCenter))
+			setStatisticsCells(resultTable, row, 3, session, window)
+			resultTable.SetCell(row, outageColumn, tview.NewTableCell(outages.String()).SetAlign(tview.AlignCenter))
+			renderHistory(res.Target, row)
+			if selected, _ := resultTable.GetSelection(); selected == row {
+				renderTimeline()
+			}
+		})
+	})
+
+	var flex *tview.Flex
+	var editGroup func()
+	editSettings := func(target string) {
+		title, probe := " Default ping settings ", cfg.Defaults
+		if target != "" {
+			title, probe = fmt.Sprintf(" Ping settings for %s ", target), cfg.Targets[target]
+		}
+		showProbeForm(app, title, probe, func(edited config.Probe) error {
+			if target == "" {
+				cfg.Defaults = edited
+			} else {
+				cfg.Targets[target] = edited
+			}
+			for _, t := range order {
+				monitor.UpdateSettings(t, cfg.Settings(t))
+			}
+			return cfg.Save()
+		}, func() {
+			app.SetRoot(flex, true)
+			app.SetFocus(resultTable)
+		})
+	}
+
+	resultTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'z':
+			zoom = (zoom + 1) % len(historyWindows)
+			setHeaders()
+			for target, row := range rows {
+				renderHistory(target, row)
+			}
+			renderTimeline()
+			return nil
+		case 'e':
+			path, err := exportPing(monitor, order, cfg)
+			if err != nil {
+				fmt.Fprintf(eventLog, "[red]Export failed: %s\n", tview.Escape(err.Error()))
+			} else {
+				fmt.Fprintf(eventLog, "[grey]Exported to %s\n", tview.Escape(path))
+			}
+			eventLog.ScrollToEnd()
+			return nil
+		case 's':
+			if row, _ := resultTable.GetSelection(); row >= 1 && row <= len(order) {
+				editSettings(order[row-1])
+			}
+			return nil
+		case 'd':
+			editSettings("")
+			return nil
+		case 'g':
+			editGroup()
+			return nil
+		case 'a':
+			showAlertForm(app, cfg.Alerts, func(edited config.Alerts) error {
+				cfg.Alerts = edited
+				tracker.SetRules(edited.Rules())
+				return cfg.Save()
+			}, func() {
+				app.SetRoot(flex, true)
+				app.SetFocus(resultTable)
+			})
+			return nil
+		}
+		return event
+	})
+
+	updateResolution := func() {
+		monitor.SetResolution(selectedFamily(familyDropDown), reResolveBox.IsChecked())
+	}
+	familyDropDown.SetSelectedFunc(func(string, int) { updateResolution() })
+	reResolveBox.SetChangedFunc(func(bool) { updateResolution() })
+
+	// Handle Enter key press to trigger the ping
+	startPing := func() {
+		var specs []ping.Spec
+		for _, text := range ping.ParseIPs(inputField.GetText()) {
+			spec, err := ping.ParseSpec(text)
+			if err == nil && !resolve.ValidHost(spec.Host) {
+				err = fmt.Errorf("invalid host: %s", spec.Host)
+			}
+			if err != nil {
+				inputField.SetFieldBackgroundColor(tcell.ColorRed)
+				inputField.SetLabel(fmt.Sprintf("%v ", err))
+				return
+			}
+			specs = append(specs, spec)
+		}
+
+		resultTable.Clear()
+		setHeaders()
+		rows = make(map[string]int)
+		order = nil
+		var pingTargets []ping.Target
+		for _, spec := range specs {
+			key := spec.String()
+			if _, dup := rows[key]; dup {
+				continue
+			}
+			i := len(order)
+			rows[key] = i + 1
+			order = append(order, key)
+			pingTargets = append(pingTargets, ping.Target{Spec: spec, Settings: cfg.Settings(key)})
+			name := spec.Host
+			if label := cfg.Label(key); label != "" {
+				name = tview.Escape(label)
+			}
+			resultTable.SetCell(i+1, 0, tview.NewTableCell(name).SetTextColor(tview.Styles.PrimaryTextColor).SetAlign(tview.AlignCenter))
+			resultTable.SetCell(i+1, 1, tview.NewTableCell(spec.Label()).SetAlign(tview.AlignCenter))
+			resultTable.SetCell(i+1, 2, tview.NewTableCell("Pinging...").SetTextColor(tcell.ColorGrey).SetAlign(tview.AlignCenter))
+		}
+		inputField.SetFieldBackgroundColor(tcell.ColorBlue)
+		inputField.SetLabel("Enter hosts (comma-separated): ")
+		log.Println("Started pinging targets:", order)
+		if pingMetrics != nil {
+			labels := make(map[string]metrics.Labels, len(order))
+			for _, target := range order {
+				groupName := group
+				if groupName == "" {
+					groupName = cfg.GroupOf(target)
+				}
+				labels[target] = metrics.Labels{Group: groupName, Label: cfg.Label(target)}
+			}
+			pingMetrics.SetTargets(labels)
+		}
+		tracker.SetTargets(order)
+		monitor.SetTargets(pingTargets)
+	}
+	inputField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			group = ""
+			groupDropDown.SetCurrentOption(0)
+			startPing()
+		}
+	})
+
+	groupDropDown.SetSelectedFunc(func(name string, index int) {
+		g, ok := cfg.Group(name)
+		if index == 0 || !ok || name == group {
+			return
+		}
+		group = name
+		inputField.SetText(strings.Join(g.Specs(), ", "))
+		startPing()
+	})
+	editGroup = func() {
+		var targets []config.GroupTarget
+		if g, ok := cfg.Group(group); ok {
+			targets = g.Targets
+		} else {
+			for _, target := range order {
+				targets = append(targets, config.GroupTarget{Target: target, Label: cfg.Label(target)})
+			}
+		}
+		showGroupForm(app, cfg, group, targets, func(saved string) {
+			app.SetRoot(flex, true)
+			app.SetFocus(resultTable)
+			group = ""
+			groupDropDown.SetOptions(groupOptions(cfg), nil)
+			groupDropDown.SetCurrentOption(0)
+			for i, name := range groupOptions(cfg) {
+				if name == saved {
+					groupDropDown.SetCurrentOption(i) // Reloads the saved group
+				}
+			}
+		})
+	}
+
+	options := tview.NewFlex().
+		AddItem(groupDropDown, 0, 1, false).
+		AddItem(familyDropDown, 0, 1, false).
+		AddItem(reResolveBox, 0, 1, false)
+
+	flex = tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(pingView, 0, 1, true).
+		AddItem(inputField, 1, 1, true).
+		AddItem(options, 1, 1, false).
+		AddItem(resultTable, 0, 5, true).
+		AddItem(tview.NewFlex().
+			AddItem(eventLog, 0, 1, false).
+			AddItem(timelineView, 0, 1, false), 8, 1, false)
+	setFocusCycle(app, flex, inputField, groupDropDown, familyDropDown, reResolveBox, resultTable, eventLog, timelineView)
+
+	stopContinuousPing()
+	pingMonitor = monitor
+	pingRecorder = recorder
+	monitor.Start()
+
+	if len(targets) > 0 {
+		inputField.SetText(strings.Join(targets, ", "))
+		startPing()
+	}
+
+	app.SetRoot(flex, true)
+	app.SetFocus(inputField)
+	setBackCapture(app)
+}
+
+func stopContinuousPing() {
+	if pingMonitor != nil {
+		pingMonitor.Stop()
+		pingMonitor = nil
+	}
+	if pingRecorder != nil {
+		pingRecorder.Close()
+		pingRecorder = nil
+	}
+	if pingMetrics != nil {
+		pingMetrics.SetTargets(nil)
+	}
+}
+
+// openRecorder prunes old sessions and prepares a new one, returning nil when
+// history is disabled or its directory is unusable
+func openRecorder(history config.History) *store.Recorder {
+	if history.Disabled {
+		return nil
+	}
+	dir, err := store.Dir()
+	if err != nil {
+		log.Printf("Ping history disabled: %v", err)
+		return nil
+	}
+	maxAge, maxSessions := history.Retention()
+	if err := store.Prune(dir, maxAge, maxSessions); err != nil {
+		log.Printf("Failed to prune ping history: %v", err)
+	}
+	return store.NewRecorder(dir)
+}
+
+// formatWindow renders a history window as 1m, 10m or 1h
+func formatWindow(d time.Duration) string {
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// pingTargetText shows the target, or its label when it has one, with its
+// resolved address when it is a hostname
+func pingTargetText(res ping.Result, label string) string {
+	name := res.Spec.Host
+	if label != "" {
+		name = fmt.Sprintf("%s: %s", tview.Escape(label), res.Spec.Host)
+	}
+	if res.Addr == "" || res.Addr == res.Spec.Host {
+		return name
+	}
+	return fmt.Sprintf("%s (%s, %s)", name, res.Addr, res.ResolveTime.Round(time.Microsecond))
+}
+
+// pingResultStatus formats a probe result for the status column
+func pingResultStatus(res ping.Result, settings ping.Settings) (string, tcell.Color) {
+	anomalies := ""
+	if res.Anomalies.Any() {
+		anomalies = fmt.Sprintf(" [yellow](%s)", res.Anomalies)
+	}
+	if res.Err != nil {
+		return fmt.Sprintf("[grey]✖ Failed: %s%s", tview.Escape(res.Err.Error()), anomalies), tcell.ColorGrey
+	}
+	status, color := ipResponseStatus(res.RTT, settings)
+	if res.HTTP != nil {
+		return fmt.Sprintf("%s%.2f ms (HTTP %d)", status, res.RTT.Seconds()*1000, res.HTTP.Status), color
+	}
+	return fmt.Sprintf("%s%.2f ms%s", status, res.RTT.Seconds()*1000, anomalies), color
+}
+
+// pingModeText describes the ICMP mode in use, highlighting unusable setups
+func pingModeText(mode ping.Mode) string {
+	if mode.Err != nil {
+		return fmt.Sprintf("[red]ICMP mode: %s", tview.Escape(mode.String()))
+	}
+	return fmt.Sprintf("[grey]ICMP mode: %s", tview.Escape(mode.String()))
+}
+
+// setStatisticsCells fills the seven statistics columns of a Ping table row,
+// starting at column col
+func setStatisticsCells(table *tview.Table, row, col int, session, window ping.Statistics) {
+	cells := []string{
+		fmt.Sprintf("%d/%d", session.Sent, session.Recv),
+		fmt.Sprintf("%.1f%%", session.Loss),
+		fmt.Sprintf("%s/%s/%s", formatMs(session.MinRTT), formatMs(session.AvgRTT), formatMs(session.MaxRTT)),
+		formatMs(session.StdDevRTT),
+		formatMs(session.Jitter),
+		fmt.Sprintf("%.0f%% %s/%s/%s/%s/%s", window.Loss, formatMs(window.MinRTT), formatMs(window.AvgRTT),
+			formatMs(window.MaxRTT), formatMs(window.StdDevRTT), formatMs(window.Jitter)),
+	}
+	for i, text := range cells {
+		table.SetCell(row, col+i, tview.NewTableCell(text).SetAlign(tview.AlignCenter))
+	}
+
+	a := session.Anomalies
+	color := tview.Styles.PrimaryTextColor
+	if a.Any() {
+		color = tcell.ColorYellow
+	}
+	table.SetCell(row, col+len(cells), tview.NewTableCell(fmt.Sprintf("%d/%d/%d", a.Duplicates, a.Reordered, a.Late)).
+		SetTextColor(color).SetAlign(tview.AlignCenter))
+}
+
+// formatMs renders a duration in milliseconds with two decimals
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.2f", d.Seconds()*1000)
+}
+
+func ipResponseStatus(responseTime time.Duration, settings ping.Settings) (string, tcell.Color) {
+	if responseTime < 0 {
+		return "[grey]✖ ", tcell.ColorGrey // Indicate failure
+	}
+	if responseTime < settings.Warn {
+		return "[green]▲ ", tcell.ColorGreen
+	} else if responseTime < settings.Crit {
+		return "[yellow]▲ ", tcell.ColorYellow
+	}
+	return "[red]▼ ", tcell.ColorRed
+}
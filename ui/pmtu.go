@@ -0,0 +1,82 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/a-tharva/ipmaster/pmtu"
+	"github.com/a-tharva/ipmaster/resolve"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+func showPMTU(app *tview.Application) {
+	pmtuView := tview.NewTextView().
+		SetText("Path MTU Page").SetTextAlign(tview.AlignCenter)
+
+	inputField := tview.NewInputField().
+		SetLabel("Enter destination host: ").
+		SetFieldWidth(0)
+	sourceDropDown := newSourceDropDown("")
+	perHopBox := tview.NewCheckbox().SetLabel("Per hop along the path: ")
+
+	resultView := tview.NewTextView().
+		SetText("Enter an IPv4 host to find the largest packet that reaches it unfragmented...").
+		SetWordWrap(true)
+
+	inputField.SetDoneFunc(func(key tcell.Key) {
+		if key != tcell.KeyEnter {
+			return
+		}
+		destHost := strings.TrimSpace(inputField.GetText())
+		if !resolve.ValidHost(destHost) {
+			inputField.SetFieldBackgroundColor(tcell.ColorRed)
+			inputField.SetLabel(fmt.Sprintf("Invalid host: %s ", destHost))
+			return
+		}
+		inputField.SetFieldBackgroundColor(tcell.ColorBlue)
+		inputField.SetLabel("Enter destination host: ")
+
+		resultView.SetText(fmt.Sprintf("Resolving %s...", destHost))
+		source := ipinfo.ParseSource(selectedSource(sourceDropDown))
+		perHop := perHopBox.IsChecked()
+
+		go func() {
+			res, err := resolve.Lookup(destHost, resolve.IPv4)
+			if err != nil {
+				app.QueueUpdateDraw(func() {
+					resultView.SetText(fmt.Sprintf("Path MTU discovery to %s failed: %v", destHost, err))
+				})
+				return
+			}
+			destIP := res.Addr.String()
+			app.QueueUpdateDraw(func() {
+				pmtuView.SetText(fmt.Sprintf("Path MTU Page\n%s", resolutionText(res)))
+			})
+
+			discoverer, err := pmtu.NewDiscoverer(destIP, app, resultView)
+			if err != nil {
+				app.QueueUpdateDraw(func() {
+					resultView.SetText(fmt.Sprintf("Path MTU discovery to %s failed: %v", destIP, err))
+				})
+				return
+			}
+			discoverer.Source = source
+			discoverer.PerHop = perHop
+			discoverer.Run() // Failures are shown in resultView
+		}()
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(pmtuView, 0, 1, false).
+		AddItem(inputField, 1, 1, true).
+		AddItem(sourceDropDown, 1, 1, false).
+		AddItem(perHopBox, 1, 1, false).
+		AddItem(resultView, 0, 5, false)
+	setFocusCycle(app, flex, inputField, sourceDropDown, perHopBox)
+
+	app.SetRoot(flex, true)
+	app.SetFocus(inputField)
+	setBackCapture(app)
+}
@@ -0,0 +1,180 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a-tharva/ipmaster/config"
+	"github.com/a-tharva/ipmaster/ipinfo"
+	"github.com/a-tharva/ipmaster/ping"
+	"github.com/rivo/tview"
+)
+
+// showProbeForm replaces the screen with a form editing probe settings.
+// Blank fields inherit from the level above. onSave receives the edited
+// settings and onClose restores the previous screen.
+func showProbeForm(app *tview.Application, title string, probe config.Probe, onSave func(config.Probe) error, onClose func()) {
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle(title)
+
+	durationField := func(label string, d config.Duration) {
+		text := ""
+		if d != 0 {
+			text = time.Duration(d).String()
+		}
+		form.AddInputField(label, text, 12, nil, nil)
+	}
+	intField := func(label string, n int) {
+		text := ""
+		if n != 0 {
+			text = strconv.Itoa(n)
+		}
+		form.AddInputField(label, text, 12, tview.InputFieldInteger, nil)
+	}
+
+	durationField("Interval (e.g. 2s)", probe.Interval)
+	durationField("Timeout", probe.Timeout)
+	intField("Packet size (bytes)", probe.Size)
+	intField("TTL", probe.TTL)
+	intField("Count per probe", probe.Count)
+	durationField("Yellow from (e.g. 100ms)", probe.Warn)
+	durationField("Red from", probe.Crit)
+	form.AddInputField("UDP payload (hex: for binary)", probe.Payload, 40, nil, nil)
+	form.AddInputField("Expected UDP reply / HTTP body", probe.Expect, 40, nil, nil)
+	intField("Expected HTTP status", probe.ExpectStatus)
+	form.AddInputField("HTTP headers (Name: value; ...)", formatHeaders(probe.Headers), 40, nil, nil)
+	form.AddFormItem(newSourceDropDown(probe.Source).SetLabel("Source interface/address"))
+	form.AddInputField("DSCP (e.g. ef, af41, 46)", probe.DSCP, 12, nil, nil)
+
+	status := tview.NewTextView().SetDynamicColors(true).
+		SetText("[grey]Leave a field blank to inherit the default")
+
+	form.AddButton("Save", func() {
+		edited, err := readProbeForm(form)
+		if err == nil {
+			err = onSave(edited)
+		}
+		if err != nil {
+			status.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+			return
+		}
+		onClose()
+	})
+	form.AddButton("Cancel", onClose)
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 1, 1, false)
+	app.SetRoot(flex, true)
+	app.SetFocus(form)
+}
+
+// readProbeForm parses the fields created by showProbeForm, in order
+func readProbeForm(form *tview.Form) (config.Probe, error) {
+	text := func(i int) string {
+		return strings.TrimSpace(form.GetFormItem(i).(*tview.InputField).GetText())
+	}
+	duration := func(i int) (config.Duration, error) {
+		if text(i) == "" {
+			return 0, nil
+		}
+		d, err := time.ParseDuration(text(i))
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("%s: invalid duration %q", form.GetFormItem(i).GetLabel(), text(i))
+		}
+		return config.Duration(d), nil
+	}
+	integer := func(i, min int) (int, error) {
+		if text(i) == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(text(i))
+		if err != nil || n < min {
+			return 0, fmt.Errorf("%s: must be at least %d", form.GetFormItem(i).GetLabel(), min)
+		}
+		return n, nil
+	}
+
+	var p config.Probe
+	var err error
+	if p.Interval, err = duration(0); err != nil {
+		return p, err
+	}
+	if p.Timeout, err = duration(1); err != nil {
+		return p, err
+	}
+	if p.Size, err = integer(2, 24); err != nil {
+		return p, err
+	}
+	if p.TTL, err = integer(3, 1); err != nil {
+		return p, err
+	}
+	if p.TTL > 255 {
+		return p, fmt.Errorf("TTL: must be at most 255")
+	}
+	if p.Count, err = integer(4, 1); err != nil {
+		return p, err
+	}
+	if p.Warn, err = duration(5); err != nil {
+		return p, err
+	}
+	if p.Crit, err = duration(6); err != nil {
+		return p, err
+	}
+	p.Payload = text(7)
+	p.Expect = text(8)
+	if p.ExpectStatus, err = integer(9, 100); err != nil {
+		return p, err
+	}
+	if p.Headers, err = parseHeaders(text(10)); err != nil {
+		return p, err
+	}
+	p.Source = selectedSource(form.GetFormItem(11).(*tview.DropDown))
+	if err := ipinfo.ParseSource(p.Source).Check(); err != nil {
+		return p, err
+	}
+	if text(12) != "" {
+		dscp, err := ping.ParseDSCP(text(12))
+		if err != nil {
+			return p, fmt.Errorf("DSCP: %v", err)
+		}
+		p.DSCP = ping.DSCPName(dscp)
+	}
+	if p.Warn != 0 && p.Crit != 0 && p.Crit < p.Warn {
+		return p, fmt.Errorf("red threshold must not be below yellow threshold")
+	}
+	return p, nil
+}
+
+// parseHeaders reads headers written as "Name: value; Name: value"
+func parseHeaders(text string) (map[string]string, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	headers := make(map[string]string)
+	for _, part := range strings.Split(text, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q, expected Name: value", strings.TrimSpace(part))
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// formatHeaders is the inverse of parseHeaders, sorted by name
+func formatHeaders(headers map[string]string) string {
+	var parts []string
+	for name, value := range headers {
+		parts = append(parts, name+": "+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/a-tharva/ipmaster/ping"
+)
+
+const historyWidth = 30 // Characters per latency sparkline
+
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// historyWindows are the zoom levels of the latency graph
+var historyWindows = []time.Duration{time.Minute, 10 * time.Minute, time.Hour}
+
+// sparkline renders samples taken within span before until as width
+// characters. Each character averages the replies in its time bucket; buckets
+// where every probe was lost, or with no probes at all, are left as gaps.
+func sparkline(samples []ping.Sample, until time.Time, span time.Duration, width int) string {
+	sums := make([]time.Duration, width)
+	counts := make([]int, width)
+	bucket := span / time.Duration(width)
+	start := until.Add(-span)
+
+	var max time.Duration
+	for _, s := range samples {
+		if s.Lost || s.Time.Before(start) {
+			continue
+		}
+		i := int(s.Time.Sub(start) / bucket)
+		if i >= width {
+			i = width - 1
+		}
+		sums[i] += s.RTT
+		counts[i]++
+	}
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= time.Duration(counts[i])
+			if sums[i] > max {
+				max = sums[i]
+			}
+		}
+	}
+
+	line := make([]rune, width)
+	for i := range line {
+		if counts[i] == 0 {
+			line[i] = ' '
+			continue
+		}
+		level := 0
+		if max > 0 {
+			level = int(sums[i] * time.Duration(len(sparkLevels)-1) / max)
+		}
+		line[i] = sparkLevels[level]
+	}
+	return string(line)
+}
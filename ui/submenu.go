@@ -1,26 +1,23 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/a-tharva/ipmaster/bgp"
 	"github.com/a-tharva/ipmaster/ipinfo"
 	"github.com/a-tharva/ipmaster/iptables"
 	"github.com/a-tharva/ipmaster/ping"
 	"github.com/a-tharva/ipmaster/ports"
+	"github.com/a-tharva/ipmaster/resolve"
 	"github.com/a-tharva/ipmaster/tracert"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 )
 
-var (
-	pingStop chan struct{} // Channel to signal stopping the ping
-)
-
 func showIPInfo(app *tview.Application) {
 	infoView := tview.NewTextView().
 		SetText("IP Info Page").SetTextAlign(tview.AlignCenter)
@@ -57,46 +54,148 @@ func showIPInfo(app *tview.Application) {
 	setBackCapture(app)
 }
 
+// traceCancel stops the running traceroute, if any
+var traceCancel context.CancelFunc
+
+func stopTrace() {
+	if traceCancel != nil {
+		traceCancel()
+		traceCancel = nil
+	}
+}
+
+// hopText formats a traceroute hop as a line of the Tracert page
+func hopText(hop tracert.Hop) string {
+	if hop.Timeout {
+		return fmt.Sprintf("Hop %2d: * (N/A) - %s\n", hop.TTL, hop.ProbesText())
+	}
+	text := fmt.Sprintf("Hop %2d: %s (%s) - %s\n", hop.TTL, hop.IP, hop.Location, hop.ProbesText())
+	if len(hop.IPs) > 1 {
+		text += fmt.Sprintf("        load-balanced: %s\n", strings.Join(hop.IPs, ", "))
+	}
+	return text
+}
+
 func showTracert(app *tview.Application) {
-	// Placeholder for tracert (to be implemented)
 	tracertView := tview.NewTextView().
 		SetText("Tracert Page").SetTextAlign(tview.AlignCenter)
 
 	inputField := tview.NewInputField().
-		SetLabel("Enter destination IP: ").
+		SetLabel("Enter destination host: ").
 		SetFieldWidth(0)
 
+	familyDropDown := newFamilyDropDown()
+	sourceDropDown := newSourceDropDown("")
+	dscpField := tview.NewInputField().
+		SetLabel("DSCP: ").
+		SetPlaceholder("be, ef, af41, ...").
+		SetFieldWidth(12)
+
+	var methods []string
+	for _, method := range tracert.Methods {
+		methods = append(methods, method.String())
+	}
+	methodDropDown := tview.NewDropDown().
+		SetLabel("Method: ").
+		SetOptions(methods, nil).
+		SetCurrentOption(0)
+	portField := tview.NewInputField().
+		SetLabel("Port: ").
+		SetPlaceholder("33434 UDP, 80 TCP").
+		SetAcceptanceFunc(tview.InputFieldInteger).
+		SetFieldWidth(18)
+	parisBox := tview.NewCheckbox().SetLabel("Paris (constant flow): ")
+
 	resultView := tview.NewTextView().
-		SetLabel("Enter an IP to see the traceroute path...").
+		SetLabel("Enter a host to see the traceroute path...").
 		SetWordWrap(true)
 
 	inputField.SetDoneFunc(func(key tcell.Key) {
 		if key == tcell.KeyEnter {
-			destIP := strings.TrimSpace(inputField.GetText())
-			if net.ParseIP(destIP) == nil {
+			destHost := strings.TrimSpace(inputField.GetText())
+			if !resolve.ValidHost(destHost) {
 				inputField.SetFieldBackgroundColor(tcell.ColorRed)
-				inputField.SetLabel(fmt.Sprintf("Invalid IP: %s ", destIP))
+				inputField.SetLabel(fmt.Sprintf("Invalid host: %s ", destHost))
 				return
 			}
 
+			dscp := 0
+			if text := strings.TrimSpace(dscpField.GetText()); text != "" {
+				var err error
+				if dscp, err = ping.ParseDSCP(text); err != nil {
+					inputField.SetFieldBackgroundColor(tcell.ColorRed)
+					inputField.SetLabel(fmt.Sprintf("Invalid DSCP: %s ", text))
+					return
+				}
+			}
+
+			port := 0
+			if text := strings.TrimSpace(portField.GetText()); text != "" {
+				p, err := strconv.Atoi(text)
+				if err != nil || p < 1 || p > 65535 {
+					inputField.SetFieldBackgroundColor(tcell.ColorRed)
+					inputField.SetLabel(fmt.Sprintf("Invalid port: %s ", text))
+					return
+				}
+				port = p
+			}
+			methodIndex, _ := methodDropDown.GetCurrentOption()
+			method := tracert.Methods[max(methodIndex, 0)]
+			paris := parisBox.IsChecked()
+
 			inputField.SetFieldBackgroundColor(tcell.ColorBlue)
-			inputField.SetLabel("Enter destination IP: ")
+			inputField.SetLabel("Enter destination host: ")
 
-			resultView.SetText(fmt.Sprintf("Tracing route to %s...", destIP))
+			resultView.SetText(fmt.Sprintf("Resolving %s...", destHost))
+			family := selectedFamily(familyDropDown)
+			source := ipinfo.ParseSource(selectedSource(sourceDropDown))
+
+			stopTrace()
+			ctx, cancel := context.WithCancel(context.Background())
+			traceCancel = cancel
 
-			//Create and configure Traacer
-			tracer, err := tracert.NewTracer(destIP, app, resultView)
-			if err != nil {
-				resultView.SetText(fmt.Sprintf("Traceroute to %s failed: %v", destIP, err))
-				return
-			}
-			// SetPrivileged(true) is default; only affects non-Windows
 			go func() {
-				err := tracer.Run()
+				res, err := resolve.Lookup(destHost, family)
+				if err != nil {
+					app.QueueUpdateDraw(func() {
+						resultView.SetText(fmt.Sprintf("Traceroute to %s failed: %v", destHost, err))
+					})
+					return
+				}
+				destIP := res.Addr.String()
+				app.QueueUpdateDraw(func() {
+					tracertView.SetText(fmt.Sprintf("Tracert Page\n%s", resolutionText(res)))
+					resultView.SetText(fmt.Sprintf("Tracing route to %s...", destIP))
+				})
+
+				tracer, err := tracert.NewTracer(destIP)
 				if err != nil {
 					app.QueueUpdateDraw(func() {
 						resultView.SetText(fmt.Sprintf("Traceroute to %s failed: %v", destIP, err))
 					})
+					return
+				}
+				// SetPrivileged(true) is default; only affects non-Windows
+				tracer.Source = source
+				tracer.DSCP = dscp
+				tracer.Method = method
+				tracer.Port = port
+				tracer.Paris = paris
+
+				var text strings.Builder
+				text.WriteString(fmt.Sprintf("Traceroute to %s (%s probes):\n", destIP, method))
+				text.WriteString("--------------------------------------------------\n")
+				err = tracer.Run(ctx, func(hop tracert.Hop) {
+					text.WriteString(hopText(hop))
+					trace := text.String()
+					app.QueueUpdateDraw(func() {
+						resultView.SetText(trace)
+					})
+				})
+				if err != nil && ctx.Err() == nil {
+					app.QueueUpdateDraw(func() {
+						resultView.SetText(fmt.Sprintf("Traceroute to %s failed: %v", destIP, err))
+					})
 				}
 			}()
 		}
@@ -105,141 +204,100 @@ func showTracert(app *tview.Application) {
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(tracertView, 0, 1, true).
 		AddItem(inputField, 1, 1, true).
+		AddItem(familyDropDown, 1, 1, false).
+		AddItem(sourceDropDown, 1, 1, false).
+		AddItem(dscpField, 1, 1, false).
+		AddItem(methodDropDown, 1, 1, false).
+		AddItem(portField, 1, 1, false).
+		AddItem(parisBox, 1, 1, false).
 		AddItem(resultView, 0, 5, true)
+	setFocusCycle(app, flex, inputField, familyDropDown, sourceDropDown, dscpField, methodDropDown, portField, parisBox)
 
 	app.SetRoot(flex, true)
 	app.SetFocus(inputField)
 	setBackCapture(app)
 }
 
-func showPing(app *tview.Application) {
-	pingView := tview.NewTextView().
-		SetText("Ping Page").SetTextAlign(tview.AlignCenter)
-
-	inputField := tview.NewInputField().
-		SetLabel("Enter IPs (comma-separated): ").
-		SetFieldWidth(0)
-
-	resultTable := tview.NewTable().SetBorders(true)
-	headers := []string{"IP Address", "Status"}
-	for i, header := range headers {
-		resultTable.SetCell(0, i,
-			tview.NewTableCell(header).SetTextColor(tview.Styles.SecondaryTextColor).SetAlign(tview.AlignCenter))
-	}
-
-	var ipPresent bool
-	ticker := time.NewTicker(2 * time.Second)
-	pingStop = make(chan struct{})
-
-	// Handle Enter key press to trigger the ping
-	inputField.SetDoneFunc(func(key tcell.Key) {
-		if key == tcell.KeyEnter {
-			ipAddresses := strings.Split(inputField.GetText(), ",")
-			resultTable.Clear()
-			for i, header := range headers {
-				resultTable.SetCell(0, i,
-					tview.NewTableCell(header).SetTextColor(tview.Styles.SecondaryTextColor).SetAlign(tview.AlignCenter))
-			}
-
-			row := 1
-			for _, ip := range ipAddresses {
-				ip = strings.TrimSpace(ip)
-				if net.ParseIP(ip) == nil {
-					inputField.SetFieldBackgroundColor(tcell.ColorRed)
-					inputField.SetLabel(fmt.Sprintf("Invalid IP: %s ", ip))
-					return
-				}
-				resultTable.SetCell(row, 0, tview.NewTableCell(ip).SetTextColor(tview.Styles.PrimaryTextColor).SetAlign(tview.AlignCenter))
-				resultTable.SetCell(row, 1, tview.NewTableCell("Pinging...").SetTextColor(tcell.ColorGrey).SetAlign(tview.AlignCenter))
-				go ping.PingIP(app, ip, row, resultTable) // Initial ping
-				row++
-			}
-			inputField.SetFieldBackgroundColor(tcell.ColorBlue)
-			inputField.SetLabel("Enter IPs (comma-separated): ")
-			// log.Println("Started pinging IPs:", ipAddresses)
-			ipPresent = true
-		}
-	})
-
-	flex := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(pingView, 0, 1, true).
-		AddItem(inputField, 1, 1, true).
-		AddItem(resultTable, 0, 5, true)
-
-	go func() {
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				if ipPresent {
-					// log.Println("Ticker fired, refreshing pings...")
-					ping.RefreshPings(app, resultTable)
-				}
-			case <-pingStop:
-				// log.Println("Ping stopped by pingStop signal")
-				return
-			}
-		}
-	}()
-
-	app.SetRoot(flex, true)
-	app.SetFocus(inputField)
-	setBackCapture(app)
-}
-
-func stopContinuousPing() {
-	if pingStop != nil {
-		close(pingStop)
-		pingStop = nil // Reinitialize to avoid panic on reuse
-	}
-	ping.StopPinging()
-	// log.Println("Continuous ping stopped")
+func showPorts(app *tview.Application) {
+	showPortsTarget(app, "")
 }
 
-func showPorts(app *tview.Application) {
+// showPortsTarget opens the Port Scan page and scans target if it is set
+func showPortsTarget(app *tview.Application, target string) {
 	portsView := tview.NewTextView().
 		SetText("Port Scan Page").SetTextAlign(tview.AlignCenter)
 
 	inputField := tview.NewInputField().
-		SetLabel("Enter target IP: ").
+		SetLabel("Enter target host: ").
 		SetFieldWidth(0)
 
+	familyDropDown := newFamilyDropDown()
+	sourceDropDown := newSourceDropDown("")
+
 	resultView := tview.NewTextView().
-		SetText("Enter an IP to scan for open ports...").
+		SetText("Enter a host to scan for open ports...").
 		SetWordWrap(true)
 
-	inputField.SetDoneFunc(func(key tcell.Key) {
-		if key == tcell.KeyEnter {
-			targetIP := strings.TrimSpace(inputField.GetText())
-			if net.ParseIP(targetIP) == nil {
-				inputField.SetFieldBackgroundColor(tcell.ColorRed)
-				inputField.SetText(fmt.Sprintf("Invalid IP: %s", targetIP))
+	startScan := func() {
+		targetHost := strings.TrimSpace(inputField.GetText())
+		if !resolve.ValidHost(targetHost) {
+			inputField.SetFieldBackgroundColor(tcell.ColorRed)
+			inputField.SetText(fmt.Sprintf("Invalid host: %s", targetHost))
+			return
+		}
+
+		inputField.SetFieldBackgroundColor(tcell.ColorBlue)
+		resultView.SetText(fmt.Sprintf("Resolving %s...", targetHost))
+		family := selectedFamily(familyDropDown)
+		source := ipinfo.ParseSource(selectedSource(sourceDropDown))
+
+		go func() {
+			res, err := resolve.Lookup(targetHost, family)
+			if err != nil {
+				app.QueueUpdateDraw(func() {
+					resultView.SetText(fmt.Sprintf("Port scan failed: %v", err))
+				})
 				return
 			}
-
-			inputField.SetFieldBackgroundColor(tcell.ColorBlue)
-			resultView.SetText(fmt.Sprintf("Scanning ports on %s...", targetIP))
+			targetIP := res.Addr.String()
+			app.QueueUpdateDraw(func() {
+				portsView.SetText(fmt.Sprintf("Port Scan Page\n%s", resolutionText(res)))
+				resultView.SetText(fmt.Sprintf("Scanning ports on %s...", targetIP))
+			})
 
 			scanner, err := ports.NewPortScanner(targetIP, app, resultView)
 			if err != nil {
-				resultView.SetText(fmt.Sprintf("Port scan failed: %v", err))
+				app.QueueUpdateDraw(func() {
+					resultView.SetText(fmt.Sprintf("Port scan failed: %v", err))
+				})
 				return
 			}
-			go func() {
-				err := scanner.ScanPorts()
-				if err != nil {
-					app.QueueUpdateDraw(func() {
-						resultView.SetText(fmt.Sprintf("Port scan on %s failed: %v", targetIP, err))
-					})
-				}
-			}()
+			scanner.Source = source
+			if err := scanner.ScanPorts(); err != nil {
+				app.QueueUpdateDraw(func() {
+					resultView.SetText(fmt.Sprintf("Port scan on %s failed: %v", targetIP, err))
+				})
+			}
+		}()
+	}
+	inputField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			startScan()
 		}
 	})
 
 	flex := tview.NewFlex().SetDirection(tview.FlexRow).
 		AddItem(portsView, 0, 1, true).
 		AddItem(inputField, 1, 1, true).
+		AddItem(familyDropDown, 1, 1, false).
+		AddItem(sourceDropDown, 1, 1, false).
 		AddItem(resultView, 0, 5, true)
+	setFocusCycle(app, flex, inputField, familyDropDown, sourceDropDown)
+
+	if target != "" {
+		inputField.SetText(target)
+		startScan()
+	}
 
 	app.SetRoot(flex, true)
 	app.SetFocus(inputField)
@@ -0,0 +1,153 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/a-tharva/ipmaster/logging"
+	"github.com/a-tharva/ipmaster/sweep"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+const sweepColumns = 6 // Hosts per row in the sweep grid
+
+var (
+	sweepStop chan struct{} // Channel to signal stopping a running sweep
+)
+
+func showSweep(app *tview.Application) {
+	sweepView := tview.NewTextView().
+		SetText("Ping Sweep Page\n[grey]e: export  m: monitor alive hosts  p: port scan selected host").
+		SetTextAlign(tview.AlignCenter).
+		SetDynamicColors(true)
+
+	inputField := tview.NewInputField().
+		SetLabel("Enter CIDR blocks or ranges: ").
+		SetFieldWidth(0)
+
+	statusView := tview.NewTextView().
+		SetText("e.g. 192.168.1.0/24, 10.0.0.10-10.0.0.50").
+		SetDynamicColors(true)
+
+	grid := tview.NewTable().SetSelectable(true, true)
+
+	// results is written from the UI goroutine only
+	var results []sweep.Host
+
+	startSweep := func() {
+		addrs, err := sweep.Expand(inputField.GetText())
+		if err != nil {
+			inputField.SetFieldBackgroundColor(tcell.ColorRed)
+			statusView.SetText(tview.Escape(err.Error()))
+			return
+		}
+		inputField.SetFieldBackgroundColor(tcell.ColorBlue)
+
+		stopSweep()
+		stop := make(chan struct{})
+		sweepStop = stop
+
+		results = make([]sweep.Host, len(addrs))
+		index := make(map[string]int, len(addrs))
+		grid.Clear()
+		for i, addr := range addrs {
+			index[addr] = i
+			grid.SetCell(i/sweepColumns, i%sweepColumns,
+				tview.NewTableCell(addr).SetTextColor(tcell.ColorGrey).SetExpansion(1))
+		}
+		statusView.SetText(fmt.Sprintf("Sweeping %d hosts...", len(addrs)))
+		app.SetFocus(grid)
+
+		go func() {
+			var done, up int
+			sweep.NewSweeper().Run(addrs, func(host sweep.Host) {
+				app.QueueUpdateDraw(func() {
+					i := index[host.Addr]
+					if sweepStop != stop {
+						return // A newer sweep replaced this one
+					}
+					results[i] = host
+					done++
+					text, color := host.Addr+" ✖", tcell.ColorRed
+					if host.Up {
+						up++
+						text, color = fmt.Sprintf("%s %s ms", host.Addr, formatMs(host.RTT)), tcell.ColorGreen
+					}
+					grid.SetCell(i/sweepColumns, i%sweepColumns,
+						tview.NewTableCell(text).SetTextColor(color).SetExpansion(1))
+					statusView.SetText(fmt.Sprintf("%d/%d probed, [green]%d up[white], %d down",
+						done, len(addrs), up, done-up))
+				})
+			}, stop)
+		}()
+	}
+
+	inputField.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			startSweep()
+		}
+	})
+
+	grid.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'e':
+			path, err := exportSweep(results)
+			if err != nil {
+				statusView.SetText(fmt.Sprintf("[red]Export failed: %s", tview.Escape(err.Error())))
+			} else {
+				statusView.SetText(fmt.Sprintf("Exported to %s", path))
+			}
+			return nil
+		case 'm':
+			if alive := sweep.Alive(results); len(alive) > 0 {
+				stopSweep()
+				showPingTargets(app, alive)
+			}
+			return nil
+		case 'p':
+			row, col := grid.GetSelection()
+			if i := row*sweepColumns + col; i < len(results) && results[i].Up {
+				stopSweep()
+				showPortsTarget(app, results[i].Addr)
+			}
+			return nil
+		}
+		return event
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(sweepView, 0, 1, false).
+		AddItem(inputField, 1, 1, true).
+		AddItem(statusView, 1, 1, false).
+		AddItem(grid, 0, 5, false)
+	setFocusCycle(app, flex, inputField, grid)
+
+	app.SetRoot(flex, true)
+	app.SetFocus(inputField)
+	setBackCapture(app)
+}
+
+func stopSweep() {
+	if sweepStop != nil {
+		close(sweepStop)
+		sweepStop = nil
+	}
+}
+
+// exportSweep writes sweep results to a timestamped CSV file in the data directory
+func exportSweep(results []sweep.Host) (string, error) {
+	path := filepath.Join(logging.GetDataDir(), fmt.Sprintf("sweep-%s.csv", time.Now().Format("20060102-150405")))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := sweep.WriteCSV(file, results); err != nil {
+		return "", err
+	}
+	return path, nil
+}